@@ -3,10 +3,14 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"os"
 
 	"aks.go/internal/keymap"
 	"aks.go/internal/translit"
+	"aks.go/keymaps"
 	"aks.go/logger"
 	"go.uber.org/zap"
 )
@@ -14,23 +18,62 @@ import (
 // main is the entry point of the Aksharamala application.
 // It parses command-line flags for configuration, initializes logging, and starts the application.
 func main() {
+	// Subcommands parse their own flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "roundtrip":
+			os.Exit(roundTrip(os.Args[2:]))
+		case "lint":
+			os.Exit(lint(os.Args[2:]))
+		}
+	}
+
 	// Parse flags
-	keymapsPath := flag.String("keymaps", "./keymaps", "Path to the keymaps directory")
+	keymapsPath := flag.String("keymaps", "", "Path to a keymaps directory to load on top of the embedded keymaps")
 	debug := flag.Bool("debug", false, "Enable debug logging")
+	keymapID := flag.String("keymap", "hindi", "ID of the keymap to use with -text, or id@version for a version other than the latest")
+	text := flag.String("text", "", "Text to map with -keymap and print")
+	explain := flag.Bool("explain", false, "Print a JSON trace of every step instead of the output")
+	dictionary := flag.String("dictionary", "", "Path to a dictionary of whole words to layer on the keymaps")
+	strict := flag.Bool("strict", false, "Fail when any keymap file fails to load instead of skipping it")
 	flag.Parse()
 
 	// Initialize the logger
 	logger.InitLogger(*debug)
 	defer logger.Sync()
 
-	store := keymap.NewKeymapStore()
-	if err := store.LoadKeymaps(*keymapsPath); err != nil {
+	store, err := loadKeymaps(*keymapsPath, *strict)
+	if err != nil {
 		logger.Error("Failed to load keymaps", zap.String("path", *keymapsPath), zap.Error(err))
-		return
+		os.Exit(1)
+	}
+	for _, file := range store.Report().Files {
+		for _, problem := range file.Errors {
+			logger.Warn("Keymap file skipped", zap.String("file", file.File), zap.String("id", file.ID), zap.String("error", problem.String()))
+		}
+	}
+	if *dictionary != "" {
+		if err := store.LoadDictionary(*dictionary); err != nil {
+			logger.Error("Failed to load dictionary", zap.String("path", *dictionary), zap.Error(err))
+			os.Exit(1)
+		}
+	}
+	for id, warnings := range store.Warnings() {
+		for _, warning := range warnings {
+			logger.Warn("Keymap warning", zap.String("id", id), zap.String("warning", warning))
+		}
 	}
 
 	aks := translit.NewAksharamala(store)
 
+	if *text != "" {
+		if err := run(aks, *keymapID, *text, *explain); err != nil {
+			logger.Error("Error during transliteration", zap.String("id", *keymapID), zap.String("input", *text), zap.Error(err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	inputs := []struct {
 		id    string
 		input string
@@ -48,3 +91,41 @@ func main() {
 		}
 	}
 }
+
+// loadKeymaps returns a store with the embedded keymaps and, when directory
+// is not empty, the keymaps of directory on top of them. Unless strict, files
+// of directory that fail to load are skipped and listed in the store's report.
+func loadKeymaps(directory string, strict bool) (*keymap.KeymapStore, error) {
+	store := keymap.NewKeymapStore()
+	store.Strict = strict
+	if err := store.LoadDefaultKeymaps(keymaps.FS); err != nil {
+		return nil, err
+	}
+	if directory != "" {
+		if err := store.LoadKeymaps(directory); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// run maps text with the given keymap and prints the output, or the trace of
+// every step as indented JSON when explain is set.
+func run(aks *translit.Aksharamala, id, text string, explain bool) error {
+	if !explain {
+		output, err := aks.TransliterateWithKeymap(id, text)
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+		return nil
+	}
+
+	trace, err := aks.ExplainWithKeymap(id, text)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(trace)
+}
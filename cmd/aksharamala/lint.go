@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"aks.go/internal/keymap"
+	"aks.go/internal/types"
+)
+
+// lintResult is the JSON output of the lint subcommand for one file.
+type lintResult struct {
+	File        string             `json:"file"`
+	Diagnostics []types.Diagnostic `json:"diagnostics"`
+}
+
+// lint implements the lint subcommand, which reports the problems of keymap
+// files. It returns the process exit code: 0 when no file has errors, 1 when
+// some do and 2 when a file cannot be read.
+func lint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the diagnostics as JSON")
+	warnings := flags.Bool("warnings", true, "Report warnings as well as errors")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: aksharamala lint [flags] file.aksj...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	code := 0
+	var results []lintResult
+	for _, file := range flags.Args() {
+		diagnostics, err := keymap.LintFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			code = 2
+			continue
+		}
+
+		result := lintResult{File: file, Diagnostics: []types.Diagnostic{}}
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Severity == types.SeverityError && code == 0 {
+				code = 1
+			}
+			if diagnostic.Severity == types.SeverityWarning && !*warnings {
+				continue
+			}
+			result.Diagnostics = append(result.Diagnostics, diagnostic)
+			if !*asJSON {
+				fmt.Printf("%s: %s\n", file, diagnostic)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	return code
+}
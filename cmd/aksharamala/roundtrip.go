@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"aks.go/internal/translit"
+)
+
+// roundTrip implements the roundtrip subcommand, which checks that a forward
+// and a reverse keymap agree on the words of a corpus and on every single
+// akshara of the forward keymap. It returns the process exit code: 0 when every
+// input is stable, 1 when some are not and 2 on errors.
+func roundTrip(args []string) int {
+	flags := flag.NewFlagSet("roundtrip", flag.ExitOnError)
+	keymapsPath := flags.String("keymaps", "", "Path to a keymaps directory to load on top of the embedded keymaps")
+	forwardID := flags.String("forward", "hindi", "ID of the keymap from romanized input to native script")
+	reverseID := flags.String("reverse", "rhindi", "ID of the keymap from native script to romanized output")
+	corpus := flags.String("corpus", "", "Path to a text file of romanized words to check")
+	aksharas := flags.Bool("aksharas", false, "Check every consonant with every matra and with a virama")
+	flags.Parse(args)
+
+	store, err := loadKeymaps(*keymapsPath, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load keymaps: %v\n", err)
+		return 2
+	}
+	aks := translit.NewAksharamala(store)
+
+	var inputs []string
+	if *corpus != "" {
+		data, err := os.ReadFile(*corpus)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read corpus: %v\n", err)
+			return 2
+		}
+		inputs = uniqueWords(string(data))
+	}
+	if *aksharas {
+		generated, err := aks.Aksharas(*forwardID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		inputs = append(inputs, generated...)
+	}
+	if len(inputs) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing to check: pass -corpus, -aksharas or both")
+		return 2
+	}
+
+	mismatches, err := aks.RoundTrip(*forwardID, *reverseID, inputs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, m := range mismatches {
+		fmt.Printf("%s: %s → %s → %s (segment %q became %q)\n", m.Input, m.Forward, m.Reverse, m.Result, m.Segment, m.Pivot)
+	}
+	fmt.Printf("%d of %d inputs did not round-trip through %s and %s\n", len(mismatches), len(inputs), *forwardID, *reverseID)
+	if len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// uniqueWords splits text on whitespace and drops repeated words.
+func uniqueWords(text string) []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
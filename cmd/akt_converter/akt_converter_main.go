@@ -21,10 +21,12 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
 	"aks.go/internal/core"
+	"aks.go/internal/keymap"
 	"aks.go/internal/types"
 	"aks.go/logger"
 	"go.uber.org/zap"
@@ -168,8 +170,24 @@ func main() {
 		}
 	}
 
+	// An overlay is updated against the keymap it extends, from the same directory
+	var baseScheme *types.TransliterationScheme
+	if existingScheme != nil && existingScheme.IsOverlay() {
+		store := keymap.NewKeymapStore()
+		if err := store.LoadKeymaps(filepath.Dir(outputFile)); err != nil {
+			logger.Warn("Failed to load the base keymap, all mappings will be written to the overlay",
+				zap.String("extends", existingScheme.Extends),
+				zap.Error(err))
+		} else if base, found := store.GetKeymap(existingScheme.Extends); found {
+			baseScheme = &base
+		} else {
+			logger.Warn("Base keymap not found, all mappings will be written to the overlay",
+				zap.String("extends", existingScheme.Extends))
+		}
+	}
+
 	// Convert to compact scheme
-	compactScheme, err := convertToCompactScheme(scheme, inputFile, existingScheme)
+	compactScheme, err := convertToCompactScheme(scheme, inputFile, existingScheme, baseScheme)
 	if err != nil {
 		logger.Error("Error converting to compact scheme", zap.Error(err))
 		return
@@ -221,7 +239,9 @@ func readAndParseInput(inputFile string) (types.TransliterationScheme, error) {
 // convertToCompactScheme converts a TransliterationScheme to a CompactTransliterationScheme.
 // It takes the scheme and input file path, returning the compact scheme and any error encountered.
 // The function also overrides the comments in the scheme with information about the conversion.
-func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string, existingScheme *types.TransliterationScheme) (types.CompactTransliterationScheme, error) {
+// When the existing scheme is an overlay, baseScheme is the flattened keymap it extends, if known;
+// mappings the base already has are left to it so that the overlay keeps its form.
+func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string, existingScheme, baseScheme *types.TransliterationScheme) (types.CompactTransliterationScheme, error) {
 	// Override comments
 	sourceFile := filepath.Base(inputFile)
 	scheme.Comments = []string{
@@ -238,9 +258,13 @@ func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string
 			Name:       scheme.Name,
 			Language:   scheme.Language,
 			Scheme:     scheme.Scheme,
+			Extends:    existingScheme.Extends,
+			Remove:     existingScheme.Remove,
+			Words:      existingScheme.Words,
 			Metadata:   scheme.Metadata,
 			Comments:   scheme.Comments,
 			Categories: make(map[string]types.Section),
+			Order:      existingScheme.CategoryNames(),
 		}
 
 		// First copy all existing categories to preserve structure
@@ -249,7 +273,8 @@ func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string
 		}
 
 		// Process each mapping from input scheme
-		for inputSection, inputContent := range scheme.Categories {
+		for _, inputSection := range scheme.CategoryNames() {
+			inputContent := scheme.Categories[inputSection]
 			for _, mapping := range inputContent.Mappings.All() {
 				// Try to find this mapping in the existing scheme
 				if section, idx, found := existingScheme.FindMapping(mapping.LHS); found {
@@ -264,6 +289,10 @@ func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string
 					logger.Info("Updated existing mapping",
 						zap.Strings("lhs", existingMappings[idx].LHS),
 						zap.String("in_section", section))
+				} else if baseScheme != nil && inheritsMapping(*baseScheme, mapping) {
+					logger.Debug("Left mapping to the base keymap",
+						zap.Strings("lhs", mapping.LHS),
+						zap.String("extends", existingScheme.Extends))
 				} else {
 					// Mapping not found, add to appropriate section from input
 					if section, exists := mergedScheme.Categories[inputSection]; exists {
@@ -276,6 +305,7 @@ func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string
 						mergedScheme.Categories[inputSection] = types.Section{
 							Mappings: core.NewMappings([]core.Mapping{mapping}),
 						}
+						mergedScheme.Order = append(mergedScheme.Order, inputSection)
 					}
 					logger.Info("Added new mapping",
 						zap.Strings("lhs", mapping.LHS),
@@ -290,6 +320,22 @@ func convertToCompactScheme(scheme types.TransliterationScheme, inputFile string
 	return types.ToCompactTransliterationScheme(scheme)
 }
 
+// inheritsMapping reports whether base maps every LHS of mapping to the same RHS,
+// so that an overlay of base need not repeat the mapping.
+func inheritsMapping(base types.TransliterationScheme, mapping core.Mapping) bool {
+	for _, lhs := range mapping.LHS {
+		section, index, found := base.FindMapping([]string{lhs})
+		if !found {
+			return false
+		}
+		existing := base.Categories[section]
+		if !slices.Equal(existing.Mappings.All()[index].RHS, mapping.RHS) {
+			return false
+		}
+	}
+	return true
+}
+
 // writeOutput writes the CompactTransliterationScheme to the specified output file.
 // It takes the scheme and output file path, returning any error encountered.
 // The function formats the scheme as JSON before writing it to the file.
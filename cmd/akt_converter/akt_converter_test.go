@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"aks.go/internal/core"
@@ -55,7 +57,7 @@ func TestConvertToCompactSchemeSectionMismatch(t *testing.T) {
 	}
 
 	// Convert with update mode
-	result, err := convertToCompactScheme(inputScheme, "test.akt", &existingScheme)
+	result, err := convertToCompactScheme(inputScheme, "test.akt", &existingScheme, nil)
 	if err != nil {
 		t.Fatalf("convertToCompactScheme failed: %v", err)
 	}
@@ -95,3 +97,105 @@ func TestConvertToCompactSchemeSectionMismatch(t *testing.T) {
 		t.Errorf("Expected comment to be 'updated comment', got %s", mapping.Comment)
 	}
 }
+
+// TestConvertToCompactSchemeOverlay verifies that updating an overlay keeps its
+// base and removals and writes only the mappings that differ from the base.
+func TestConvertToCompactSchemeOverlay(t *testing.T) {
+	baseScheme := types.TransliterationScheme{
+		ID: "hindi",
+		Categories: map[string]types.Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"f"}, RHS: []string{"फ़"}},
+			})},
+		},
+	}
+	existingScheme := types.TransliterationScheme{
+		ID:      "marathi",
+		Extends: "hindi",
+		Remove:  &types.Removal{LHS: []string{"Y"}},
+		Categories: map[string]types.Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"kt"}, RHS: []string{"क्त"}},
+			})},
+		},
+	}
+	inputScheme := types.TransliterationScheme{
+		ID: "marathi",
+		Categories: map[string]types.Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"f"}, RHS: []string{"फ"}},
+				{LHS: []string{"kt"}, RHS: []string{"क्‍त"}},
+			})},
+		},
+	}
+
+	result, err := convertToCompactScheme(inputScheme, "Marathi.akt", &existingScheme, &baseScheme)
+	if err != nil {
+		t.Fatalf("convertToCompactScheme failed: %v", err)
+	}
+	formatted, err := FormatSchemeJSON(result)
+	if err != nil {
+		t.Fatalf("FormatSchemeJSON failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		`  "extends": "hindi",`,
+		`  "remove": {"lhs":["Y"]},`,
+		`{"lhs":["kt"],"rhs":["क्‍त"]}`,
+		`{"lhs":["f"],"rhs":["फ"]}`,
+	} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("Expected %s in the output:\n%s", expected, formatted)
+		}
+	}
+	if strings.Contains(formatted, `"lhs":["k"]`) {
+		t.Errorf("Mapping inherited unchanged from the base was written to the overlay:\n%s", formatted)
+	}
+}
+
+// TestConvertToCompactSchemeWords verifies that updating a keymap keeps its
+// whole words, which .akt files do not have.
+func TestConvertToCompactSchemeWords(t *testing.T) {
+	existingScheme := types.TransliterationScheme{
+		ID: "hindi",
+		Categories: map[string]types.Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+			})},
+		},
+		Words: []core.Mapping{
+			{LHS: []string{"Delhi"}, RHS: []string{"दिल्ली"}, Comment: "city"},
+		},
+	}
+	inputScheme := types.TransliterationScheme{
+		ID: "hindi",
+		Categories: map[string]types.Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+			})},
+		},
+	}
+
+	result, err := convertToCompactScheme(inputScheme, "Hindi.akt", &existingScheme, nil)
+	if err != nil {
+		t.Fatalf("convertToCompactScheme failed: %v", err)
+	}
+	formatted, err := FormatSchemeJSON(result)
+	if err != nil {
+		t.Fatalf("FormatSchemeJSON failed: %v", err)
+	}
+
+	expected := "  \"words\": [\n    {\"lhs\":[\"Delhi\"],\"rhs\":[\"दिल्ली\"],\"comment\":\"city\"}\n  ]\n}\n"
+	if !strings.HasSuffix(formatted, expected) {
+		t.Errorf("Expected the words at the end of the output:\n%s", formatted)
+	}
+	var scheme types.TransliterationScheme
+	if err := json.Unmarshal([]byte(formatted), &scheme); err != nil {
+		t.Fatalf("Output is not a valid keymap: %v", err)
+	}
+	if len(scheme.Words) != 1 || scheme.Words[0].RHS[0] != "दिल्ली" {
+		t.Errorf("Expected the word Delhi to be kept, got %v", scheme.Words)
+	}
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"encoding/json"
-	"sort"
 	"strings"
 
+	"aks.go/internal/core"
 	"aks.go/internal/types"
 )
 
@@ -21,17 +21,26 @@ func FormatSchemeJSON(scheme types.CompactTransliterationScheme) (string, error)
 	}
 
 	// Write categories
-	if err := writeCategories(&output, scheme.Categories); err != nil {
+	if err := writeCategories(&output, scheme.Categories, scheme.CategoryNames()); err != nil {
 		return "", err
 	}
 
-	output.WriteString("}\n")
+	// Write whole words, if any
+	if len(scheme.Words) > 0 {
+		output.WriteString(",\n")
+		if err := writeWords(&output, scheme.Words); err != nil {
+			return "", err
+		}
+	}
+
+	output.WriteString("\n}\n")
 	return output.String(), nil
 }
 
 // writeMetadataFields writes the metadata fields of the scheme to the provided string builder.
 // It takes a pointer to strings.Builder and a CompactTransliterationScheme.
-// The function writes the metadata fields in the following order: comments, version, id, name, license, language, scheme, and metadata.
+// The function writes the metadata fields in the following order: comments, version, id, name, license, language, scheme,
+// extends and remove for overlays, and metadata.
 func writeMetadataFields(w *strings.Builder, scheme types.CompactTransliterationScheme) error {
 	// Comments with default formatting (one per line)
 	w.WriteString(`  "comments": [`)
@@ -56,6 +65,20 @@ func writeMetadataFields(w *strings.Builder, scheme types.CompactTransliteration
 	writeStringField(w, "language", scheme.Language)
 	writeStringField(w, "scheme", scheme.Scheme)
 
+	// An overlay keeps its base and removals rather than being expanded
+	if scheme.Extends != "" {
+		writeStringField(w, "extends", scheme.Extends)
+	}
+	if scheme.Remove != nil {
+		w.WriteString(`  "remove": `)
+		removeJSON, err := json.Marshal(scheme.Remove)
+		if err != nil {
+			return err
+		}
+		w.Write(removeJSON)
+		w.WriteString(",\n")
+	}
+
 	// Metadata object
 	w.WriteString(`  "metadata": `)
 	metadataJSON, err := json.Marshal(scheme.Metadata)
@@ -81,19 +104,13 @@ func writeStringField(w *strings.Builder, field, value string) {
 }
 
 // writeCategories writes the categories of the scheme to the provided string builder.
-// It takes a pointer to strings.Builder and a map of categories.
-// The function writes the categories in sorted order, with each category containing a list of mappings.
-func writeCategories(w *strings.Builder, categories map[string]json.RawMessage) error {
+// It takes a pointer to strings.Builder, a map of categories and their names in order.
+// The function writes the categories in that order, which is their precedence,
+// with each category containing a list of mappings.
+func writeCategories(w *strings.Builder, categories map[string]json.RawMessage, categoryNames []string) error {
 	w.WriteString(`  "categories": {`)
 
-	// Get sorted category names
-	categoryNames := make([]string, 0, len(categories))
-	for name := range categories {
-		categoryNames = append(categoryNames, name)
-	}
-	sort.Strings(categoryNames)
-
-	// Write categories in sorted order
+	// Write categories in precedence order
 	for i, category := range categoryNames {
 		mappings := categories[category]
 		if i > 0 {
@@ -108,48 +125,77 @@ func writeCategories(w *strings.Builder, categories map[string]json.RawMessage)
 			return err
 		}
 
-		// Write each mapping
-		for i, entry := range entries {
-			w.WriteString("      {")
-
-			// lhs first
-			if lhs, ok := entry["lhs"]; ok {
-				lhsJSON, err := json.Marshal(lhs)
-				if err != nil {
-					return err
-				}
-				w.WriteString(`"lhs":`)
-				w.Write(lhsJSON)
-			}
+		if err := writeMappings(w, "      ", entries); err != nil {
+			return err
+		}
+		w.WriteString("    ]")
+	}
+	w.WriteString("\n  }")
+	return nil
+}
 
-			// rhs second
-			if rhs, ok := entry["rhs"]; ok {
-				w.WriteString(`,"rhs":`)
-				rhsJSON, err := json.Marshal(rhs)
-				if err != nil {
-					return err
-				}
-				w.Write(rhsJSON)
+// writeWords writes the whole words of the scheme, in the same form as the
+// mappings of a category.
+func writeWords(w *strings.Builder, words []core.Mapping) error {
+	wordsJSON, err := json.Marshal(words)
+	if err != nil {
+		return err
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(wordsJSON, &entries); err != nil {
+		return err
+	}
+
+	w.WriteString("  \"words\": [\n")
+	if err := writeMappings(w, "    ", entries); err != nil {
+		return err
+	}
+	w.WriteString("  ]")
+	return nil
+}
+
+// writeMappings writes mappings one per line after indent, with the LHS
+// first, the RHS second and the comment, if any, last.
+func writeMappings(w *strings.Builder, indent string, entries []map[string]interface{}) error {
+	for i, entry := range entries {
+		w.WriteString(indent)
+		w.WriteString("{")
+
+		// lhs first
+		if lhs, ok := entry["lhs"]; ok {
+			lhsJSON, err := json.Marshal(lhs)
+			if err != nil {
+				return err
 			}
+			w.WriteString(`"lhs":`)
+			w.Write(lhsJSON)
+		}
 
-			// comment last, if it exists
-			if comment, ok := entry["comment"]; ok && comment != nil {
-				w.WriteString(`,"comment":`)
-				commentJSON, err := json.Marshal(comment)
-				if err != nil {
-					return err
-				}
-				w.Write(commentJSON)
+		// rhs second
+		if rhs, ok := entry["rhs"]; ok {
+			w.WriteString(`,"rhs":`)
+			rhsJSON, err := json.Marshal(rhs)
+			if err != nil {
+				return err
 			}
+			w.Write(rhsJSON)
+		}
 
-			w.WriteString("}")
-			if i < len(entries)-1 {
-				w.WriteString(",")
+		// comment last, if it exists
+		if comment, ok := entry["comment"]; ok && comment != nil {
+			w.WriteString(`,"comment":`)
+			commentJSON, err := json.Marshal(comment)
+			if err != nil {
+				return err
 			}
-			w.WriteString("\n")
+			w.Write(commentJSON)
 		}
-		w.WriteString("    ]")
+
+		w.WriteString("}")
+		if i < len(entries)-1 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n")
 	}
-	w.WriteString("\n  }\n")
 	return nil
 }
@@ -51,6 +51,9 @@ func ParseAKTFile(file *os.File) (types.TransliterationScheme, error) {
 
 			// Start a new section or reuse an existing one
 			currentCategory = match[1]
+			if _, exists := scheme.Categories[currentCategory]; !exists {
+				scheme.Order = append(scheme.Order, currentCategory)
+			}
 			section = *types.GetOrCreate(scheme, currentCategory)
 			lastMapping = nil
 			continue
@@ -65,6 +68,9 @@ func ParseAKTFile(file *os.File) (types.TransliterationScheme, error) {
 
 			// Start a new pseudo-section or reuse an existing one
 			currentCategory = strings.ToLower(strings.Fields(match[1])[0])
+			if _, exists := scheme.Categories[currentCategory]; !exists {
+				scheme.Order = append(scheme.Order, currentCategory)
+			}
 			section = *types.GetOrCreate(scheme, currentCategory)
 			lastMapping = nil
 			continue
@@ -2,46 +2,114 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"aks.go/internal/keymap"
 	"aks.go/internal/translit"
+	"aks.go/internal/types"
+	"aks.go/keymaps"
 )
 
 type TransliterationRequest struct {
-	Text     string `json:"text"`
-	KeymapID string `json:"keymapId"`
+	Text string `json:"text"`
+	// KeymapID names the latest version of a keymap, or a version as "id@version"
+	KeymapID  string `json:"keymapId"`
+	Explain   bool   `json:"explain,omitempty"`
+	Alignment bool   `json:"alignment,omitempty"`
+	// Pipeline, when set, chains these keymaps instead of using KeymapID
+	Pipeline []string `json:"pipeline,omitempty"`
+	// Schwa, "delete" or "strict", overrides the schwa deletion of the keymap,
+	// or of the final keymap of Pipeline
+	Schwa string `json:"schwa,omitempty"`
 }
 
 type TransliterationResponse struct {
 	Result string `json:"result"`
+	// Keymap and Version name the keymap version that produced Result
+	Keymap    string              `json:"keymap,omitempty"`
+	Version   string              `json:"version,omitempty"`
+	Keymaps   []string            `json:"keymaps,omitempty"` // Versioned IDs of the pipeline stages
+	Trace     *translit.Trace     `json:"trace,omitempty"`
+	Alignment []translit.Segment  `json:"alignment,omitempty"`
+	Pivots    []string            `json:"pivots,omitempty"`
+	Lost      []translit.LostText `json:"lost,omitempty"`
+	Gaps      []translit.PivotGap `json:"gaps,omitempty"`
 }
 
 type Keymap struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Versions []string `json:"versions,omitempty"` // Loaded versions, earliest first
 }
 
+// aksharamala is shared by all request handlers; it is safe for concurrent use
+// because every call runs in its own translit.Session.
 var aksharamala *translit.Aksharamala
 
-func init() {
-	// Initialize keymap store with keymaps from the keymaps directory
+// loadKeymaps returns a store with the embedded keymaps and, when directory
+// is not empty, the keymaps of directory on top of them. Files of directory
+// that fail to load are logged and skipped, or stop the server when strict.
+func loadKeymaps(directory string, strict bool) *keymap.KeymapStore {
 	store := keymap.NewKeymapStore()
-	keymapsDir, err := filepath.Abs("./keymaps")
-	if err != nil {
-		log.Fatalf("Failed to get absolute path: %v", err)
+	store.Strict = strict
+	if err := store.LoadDefaultKeymaps(keymaps.FS); err != nil {
+		log.Fatalf("Failed to load the embedded keymaps: %v", err)
 	}
-
-	if err := store.LoadKeymaps(keymapsDir); err != nil {
-		log.Printf("Warning: Failed to load keymaps: %v", err)
+	if directory != "" {
+		if err := store.LoadKeymaps(directory); err != nil {
+			if strict {
+				log.Fatalf("Failed to load keymaps: %v", err)
+			}
+			log.Printf("Warning: Failed to load keymaps: %v", err)
+		}
 	}
-
-	aksharamala = translit.NewAksharamala(store)
+	for _, file := range store.Report().Files {
+		log.Printf("Warning: skipped %s", file)
+	}
+	for id, warnings := range store.Warnings() {
+		for _, warning := range warnings {
+			log.Printf("Warning: keymap '%s': %s", id, warning)
+		}
+	}
+	return store
 }
 
 func main() {
+	keymapsFlag := flag.String("keymaps", "", "Path to a keymaps directory to load on top of the embedded keymaps")
+	watch := flag.Duration("watch", 0, "Reload changed keymap files of -keymaps, checking at this interval (0 disables)")
+	strict := flag.Bool("strict", false, "Refuse to start when any keymap file of -keymaps fails to load")
+	flag.Parse()
+
+	// Initialize keymap store with the embedded keymaps and any on disk
+	keymapsDir := *keymapsFlag
+	if keymapsDir != "" {
+		var err error
+		if keymapsDir, err = filepath.Abs(keymapsDir); err != nil {
+			log.Fatalf("Failed to get absolute path: %v", err)
+		}
+	}
+	store := loadKeymaps(keymapsDir, *strict)
+	aksharamala = translit.NewAksharamala(store)
+
+	// Reload edited keymaps without a restart when asked to
+	if *watch > 0 && keymapsDir != "" {
+		store.Subscribe(func(ids []string) {
+			log.Printf("Reloaded keymaps: %s", strings.Join(ids, ", "))
+		})
+		watcher, err := store.Watch(keymapsDir, *watch, func(err error) {
+			log.Printf("Warning: watching %s: %v", keymapsDir, err)
+		})
+		if err != nil {
+			log.Fatalf("Failed to watch keymaps: %v", err)
+		}
+		defer watcher.Stop()
+	}
+
 	// Health check endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -60,14 +128,31 @@ func main() {
 			{ID: "Hindi", Name: "Hindi (ITRANS -> Unicode)"},
 			{ID: "Marathi", Name: "Marathi (ITRANS -> Unicode)"},
 			{ID: "TeluguRts", Name: "Telugu (RTS -> Unicode)"},
+			{ID: "IAST", Name: "Sanskrit (IAST -> Unicode)"},
+			{ID: "ISO15919", Name: "Devanagari (ISO 15919 -> Unicode)"},
 			{ID: "RHindi", Name: "Hindi (Unicode -> ITRANS)"},
 			{ID: "RSanskrit", Name: "Sanskrit (Unicode -> ITRANS)"},
+			{ID: "RIAST", Name: "Sanskrit (Unicode -> IAST)"},
+			{ID: "RISO15919", Name: "Devanagari (Unicode -> ISO 15919)"},
+		}
+		for i := range keymaps {
+			keymaps[i].Versions = store.ListVersions(keymaps[i].ID)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(keymaps)
 	})
 
+	// Report the keymap files that failed to load and why
+	http.HandleFunc("/api/keymaps/report", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(store.Report())
+	})
+
 	// Map the given text using the selected keymap
 	http.HandleFunc("/api/m", func(w http.ResponseWriter, r *http.Request) {
 		// Handle OPTIONS (CORS preflight request)
@@ -76,16 +161,24 @@ func main() {
 			return
 		}
 
-		var text, keymapID string
+		var text, keymapID, schwa string
+		var explain, alignment bool
+		var pipeline []string
 
 		if r.Method == http.MethodGet {
 			// Extract parameters from the URL for GET requests
 			query := r.URL.Query()
 			text = query.Get("text")
 			keymapID = query.Get("keymapId")
+			explain, _ = strconv.ParseBool(query.Get("explain"))
+			alignment, _ = strconv.ParseBool(query.Get("alignment"))
+			schwa = query.Get("schwa")
+			if ids := query.Get("pipeline"); ids != "" {
+				pipeline = strings.Split(ids, ",")
+			}
 
-			if text == "" || keymapID == "" {
-				http.Error(w, "Missing required parameters: text and keymapId", http.StatusBadRequest)
+			if text == "" || (keymapID == "" && len(pipeline) == 0) {
+				http.Error(w, "Missing required parameters: text and keymapId or pipeline", http.StatusBadRequest)
 				return
 			}
 		} else if r.Method == http.MethodPost {
@@ -97,20 +190,84 @@ func main() {
 			}
 			text = req.Text
 			keymapID = req.KeymapID
+			explain = req.Explain
+			alignment = req.Alignment
+			pipeline = req.Pipeline
+			schwa = req.Schwa
 		} else {
 			// Reject other methods
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Perform transliteration
-		result, err := aksharamala.TransliterateWithKeymap(keymapID, text)
+		if schwa != "" && schwa != types.SchwaDelete && schwa != types.SchwaStrict {
+			http.Error(w, "Invalid schwa mode: "+schwa, http.StatusBadRequest)
+			return
+		}
+
+		// Chain the keymaps of a pipeline, reporting what does not survive the pivots
+		if len(pipeline) > 0 {
+			chain, err := aksharamala.NewPipeline(pipeline...)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if schwa != "" {
+				chain.SetSchwaDeletion(schwa == types.SchwaDelete)
+			}
+			result, err := chain.Run(text)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(TransliterationResponse{
+				Result:  result.Output,
+				Keymaps: result.Keymaps,
+				Pivots:  result.Pivots,
+				Lost:    result.Lost,
+				Gaps:    chain.Gaps(),
+			})
+			return
+		}
+
+		session, err := aksharamala.NewSession(keymapID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if schwa != "" {
+			session.SetSchwaDeletion(schwa == types.SchwaDelete)
+		}
 
-		response := TransliterationResponse{Result: result}
+		// Perform transliteration, with the alignment and a trace of every step if requested
+		response := TransliterationResponse{Keymap: session.KeymapID(), Version: session.Version()}
+		if alignment {
+			result, err := session.MapAligned(text)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response.Result = result.Output
+			response.Alignment = result.Alignment
+		}
+		if explain {
+			trace, err := session.Explain(text)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response.Result = trace.Output
+			response.Trace = trace
+		}
+		if !alignment && !explain {
+			result, err := session.Map(text)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response.Result = result
+		}
 
 		// ✅ Ensure correct UTF-8 encoding
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
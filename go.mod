@@ -5,6 +5,7 @@ go 1.21.5
 require (
 	github.com/stretchr/testify v1.8.1
 	go.uber.org/zap v1.27.0
+	golang.org/x/text v0.22.0
 )
 
 require (
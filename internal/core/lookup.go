@@ -1,5 +1,33 @@
 package core
 
+// Control outputs a keymap can assign to a key. They act on the engine state
+// instead of being written to the output as they are.
+const (
+	// SyllableBreak ends the current syllable without writing anything, so the
+	// next vowel is not joined as a matra, as in `^` of TeluguRts.
+	SyllableBreak = "\u0000"
+	// ZWNJ ends a consonant with an explicit virama that does not form a conjunct.
+	ZWNJ = "\u200C"
+	// ZWJ ends a consonant with a virama that requests its half form.
+	ZWJ = "\u200D"
+	// ToggleMarker switches between transliteration and pass-through of the
+	// input, as in `\#` of TeluguRts.
+	ToggleMarker = "\uFFFE"
+	// Nothing writes nothing where a key has no output of its own, as `.c` of
+	// Hindi, which is a matra after a consonant and nothing elsewhere.
+	Nothing = "\uFFFF"
+)
+
+// Categories of lookup results that no category of a keymap produced.
+const (
+	// CategoryOther is the category of unmatched input, spaces, whole words of
+	// a dictionary and pass-through text.
+	CategoryOther = "other"
+	// CategoryWordBoundary is the category of a (W) variant written at the end
+	// of a word, which takes no virama.
+	CategoryWordBoundary = "word_boundary"
+)
+
 // LookupResult represents the result of a lookup operation.
 type LookupResult struct {
 	Output      string // Primary output (first RHS)
@@ -7,6 +35,8 @@ type LookupResult struct {
 	Category    string // Category of the mapping
 	Found       bool   // Whether the lookup found a match
 	MatchLength int    // Length of the current match in runes
+	Index       int    // Index of the mapping within its category
+	Alternative int    // Index of the RHS alternative that produced Output
 }
 
 // LookupTable maps input strings to their lookup results
@@ -17,7 +47,7 @@ func (table LookupTable) Lookup(char string) LookupResult {
 	if result, exists := table[char]; exists {
 		return result
 	}
-	return LookupResult{Found: false, Category: "other"}
+	return LookupResult{Found: false, Category: CategoryOther}
 }
 
 // Lookup maintains the same simple interface but uses direct mapping
@@ -0,0 +1,102 @@
+package core
+
+// TrieEntry is a single mapping reachable through a Trie.
+type TrieEntry struct {
+	Category string   // Category the mapping belongs to
+	Index    int      // Index of the mapping within its category
+	RHS      []string // Right-hand side alternatives of the mapping
+}
+
+// TrieMatch describes one LHS that matched at a given position.
+type TrieMatch struct {
+	Length  int         // Length of the match in runes
+	Entries []TrieEntry // Mappings sharing this LHS, in precedence order
+}
+
+// Trie is a rune-keyed prefix tree over LHS strings. It answers
+// longest-match queries in time proportional to the match length rather
+// than the size of the keymap. A Trie is built once with Insert and must
+// not be modified after it has been shared.
+type Trie struct {
+	root   trieNode
+	maxLen int // Longest key in runes
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []TrieEntry
+}
+
+// NewTrie creates an empty Trie.
+func NewTrie() *Trie {
+	return &Trie{}
+}
+
+// Insert adds an entry for the given key. Entries inserted for the same key
+// keep their insertion order. Empty keys are ignored.
+func (t *Trie) Insert(key string, entry TrieEntry) {
+	if key == "" {
+		return
+	}
+
+	node := &t.root
+	length := 0
+	for _, r := range key {
+		if node.children == nil {
+			node.children = make(map[rune]*trieNode)
+		}
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		node = child
+		length++
+	}
+	node.entries = append(node.entries, entry)
+
+	if length > t.maxLen {
+		t.maxLen = length
+	}
+}
+
+// Get returns the entries stored for an exact key.
+func (t *Trie) Get(key string) ([]TrieEntry, bool) {
+	node := &t.root
+	for _, r := range key {
+		node = node.children[r]
+		if node == nil {
+			return nil, false
+		}
+	}
+	return node.entries, len(node.entries) > 0
+}
+
+// MaxLength returns the length in runes of the longest key in the trie.
+func (t *Trie) MaxLength() int {
+	return t.maxLen
+}
+
+// MatchRunes returns every key that is a prefix of runes[start:], longest first.
+func (t *Trie) MatchRunes(runes []rune, start int) []TrieMatch {
+	var matches []TrieMatch
+	node := &t.root
+	for pos := start; pos < len(runes); pos++ {
+		node = node.children[runes[pos]]
+		if node == nil {
+			break
+		}
+		if len(node.entries) > 0 {
+			matches = append(matches, TrieMatch{Length: pos - start + 1, Entries: node.entries})
+		}
+	}
+	reverseMatches(matches)
+	return matches
+}
+
+// reverseMatches orders matches from the longest to the shortest.
+func reverseMatches(matches []TrieMatch) {
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+}
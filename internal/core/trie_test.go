@@ -0,0 +1,61 @@
+package core
+
+import "testing"
+
+// TestTrieLongestFirst verifies that all prefixes are reported, longest first,
+// with lengths counted in runes.
+func TestTrieLongestFirst(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert("k", TrieEntry{Category: "consonants", Index: 0, RHS: []string{"क"}})
+	trie.Insert("kh", TrieEntry{Category: "consonants", Index: 1, RHS: []string{"ख"}})
+	trie.Insert("khh", TrieEntry{Category: "consonants", Index: 2, RHS: []string{"ख़"}})
+	trie.Insert("ā", TrieEntry{Category: "vowels", Index: 0, RHS: []string{"आ"}})
+
+	matches := trie.MatchRunes([]rune("akhx"), 1)
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(matches))
+	}
+	if matches[0].Length != 2 || matches[0].Entries[0].RHS[0] != "ख" {
+		t.Errorf("Expected longest match 'kh' first, got %+v", matches[0])
+	}
+	if matches[1].Length != 1 || matches[1].Entries[0].RHS[0] != "क" {
+		t.Errorf("Expected 'k' second, got %+v", matches[1])
+	}
+
+	matches = trie.MatchRunes([]rune("āx"), 0)
+	if len(matches) != 1 || matches[0].Length != 1 {
+		t.Errorf("Expected a single 1-rune match for 'ā', got %+v", matches)
+	}
+
+	if matches := trie.MatchRunes([]rune("x"), 0); len(matches) != 0 {
+		t.Errorf("Expected no match for 'x', got %+v", matches)
+	}
+	if trie.MaxLength() != 3 {
+		t.Errorf("Expected max length 3, got %d", trie.MaxLength())
+	}
+}
+
+// TestTrieMatchRunes verifies rune-based matching and exact lookups.
+func TestTrieMatchRunes(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert("क", TrieEntry{Category: "consonants", RHS: []string{"k"}})
+	trie.Insert("क्ष", TrieEntry{Category: "consonants", RHS: []string{"x"}})
+	trie.Insert("क", TrieEntry{Category: "others", RHS: []string{"q"}})
+
+	matches := trie.MatchRunes([]rune("क्षमा"), 0)
+	if len(matches) != 2 || matches[0].Length != 3 || matches[1].Length != 1 {
+		t.Fatalf("Unexpected matches: %+v", matches)
+	}
+
+	entries, found := trie.Get("क")
+	if !found || len(entries) != 2 {
+		t.Fatalf("Expected two entries for 'क', got %+v", entries)
+	}
+	if entries[0].Category != "consonants" || entries[1].Category != "others" {
+		t.Errorf("Expected entries in insertion order, got %+v", entries)
+	}
+
+	if _, found := trie.Get("क्"); found {
+		t.Errorf("Expected no entry for an inner node")
+	}
+}
@@ -0,0 +1,136 @@
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"aks.go/internal/core"
+	"aks.go/internal/types"
+)
+
+// dictionaryLayer is a dictionary loaded into the store and where it came from.
+type dictionaryLayer struct {
+	source     string
+	dictionary types.Dictionary
+}
+
+// LoadDictionary loads a dictionary file of whole-word overrides on top of the
+// keymap's own words and of the dictionaries loaded before it, so a user's
+// dictionary overrides the shipped one. Loading the same file again replaces
+// its words. The keymaps the dictionary names must be loaded.
+func (store *KeymapStore) LoadDictionary(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	return store.loadDictionary(data, filePath)
+}
+
+// loadDictionary decodes and adds the dictionary read from filePath.
+func (store *KeymapStore) loadDictionary(data []byte, filePath string) error {
+	dictionary, err := decodeDictionary(data, filePath)
+	if err != nil {
+		return err
+	}
+	_, err = store.update(nil, nil, []dictionaryLayer{{source: filePath, dictionary: dictionary}}, nil, true, nil)
+	return err
+}
+
+// decodeDictionary decodes and validates the dictionary read from filePath.
+func decodeDictionary(data []byte, filePath string) (types.Dictionary, error) {
+	var dictionary types.Dictionary
+	if err := json.Unmarshal(data, &dictionary); err != nil {
+		return types.Dictionary{}, fmt.Errorf("failed to decode dictionary %s: %w", filePath, err)
+	}
+	if err := dictionary.Validate(); err != nil {
+		return types.Dictionary{}, fmt.Errorf("failed to load dictionary %s: %w", filePath, err)
+	}
+	return dictionary, nil
+}
+
+// AddDictionary adds a dictionary of whole-word overrides like LoadDictionary.
+func (store *KeymapStore) AddDictionary(dictionary types.Dictionary) error {
+	if err := dictionary.Validate(); err != nil {
+		return err
+	}
+	_, err := store.update(nil, nil, []dictionaryLayer{{dictionary: dictionary}}, nil, true, nil)
+	return err
+}
+
+// RemoveDictionary drops the words of the dictionary loaded from filePath, as
+// when the file is deleted. It does nothing if no dictionary came from it.
+func (store *KeymapStore) RemoveDictionary(filePath string) {
+	// Nothing that resolved before fails to resolve now
+	_, _ = store.update(nil, nil, nil, []string{filePath}, true, nil)
+}
+
+// checkDictionary returns an error if a keymap the dictionary of layer names
+// is not among keymaps.
+func checkDictionary(keymaps map[string]types.TransliterationScheme, layer dictionaryLayer) error {
+	for _, id := range []string{layer.dictionary.Keymap, layer.dictionary.Reverse} {
+		if _, exists := resolveRef(keymaps, id); id != "" && !exists {
+			err := fmt.Errorf("keymap with ID '%s' not found", id)
+			if layer.source != "" {
+				err = fmt.Errorf("failed to load dictionary %s: %w", layer.source, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// layerDictionaries returns dictionaries with layers on top, each replacing
+// the one from the same file if any, and without those from the dropped
+// files, along with the layers that were added, replaced or dropped.
+func layerDictionaries(dictionaries, layers []dictionaryLayer, dropped []string) ([]dictionaryLayer, []dictionaryLayer) {
+	var changed []dictionaryLayer
+	result := make([]dictionaryLayer, 0, len(dictionaries)+len(layers))
+	for _, existing := range dictionaries {
+		if existing.source != "" && slices.Contains(dropped, existing.source) {
+			changed = append(changed, existing)
+			continue
+		}
+		result = append(result, existing)
+	}
+	for _, layer := range layers {
+		i := -1
+		if layer.source != "" {
+			i = slices.IndexFunc(result, func(existing dictionaryLayer) bool { return existing.source == layer.source })
+		}
+		if i >= 0 {
+			changed = append(changed, result[i])
+			result[i] = layer
+		} else {
+			result = append(result, layer)
+		}
+		changed = append(changed, layer)
+	}
+	return result, changed
+}
+
+// namesKeymap reports whether the dictionary of any of layers names the keymap id.
+func namesKeymap(layers []dictionaryLayer, id string) bool {
+	for _, layer := range layers {
+		if refersTo(layer.dictionary.Keymap, id) || (layer.dictionary.Reverse != "" && refersTo(layer.dictionary.Reverse, id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wordLayers returns the words dictionaries give the keymap id, one layer per
+// dictionary in load order.
+func wordLayers(dictionaries []dictionaryLayer, id string) [][]core.Mapping {
+	var layers [][]core.Mapping
+	for _, layer := range dictionaries {
+		if refersTo(layer.dictionary.Keymap, id) {
+			layers = append(layers, layer.dictionary.Words)
+		}
+		if layer.dictionary.Reverse != "" && refersTo(layer.dictionary.Reverse, id) {
+			layers = append(layers, layer.dictionary.Reversed())
+		}
+	}
+	return layers
+}
@@ -0,0 +1,52 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDictionary(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{
+		"Base.aksj": baseKeymap,
+		"Base.aksd": `{"keymap": "base", "words": [{"lhs": ["kay"], "rhs": ["के"]}]}`,
+	})
+
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	compiled, _ := store.GetCompiled("base")
+	output, _, found := compiled.WordAt([]rune("kay"), 0, 0)
+	assert.True(t, found, "dictionaries next to the keymaps are loaded with them")
+	assert.Equal(t, "के", output)
+
+	// Loading a file again replaces its words
+	path := filepath.Join(directory, "Base.aksd")
+	if err := os.WriteFile(path, []byte(`{"keymap": "Base", "words": [{"lhs": ["kii"], "rhs": ["की"]}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, store.LoadDictionary(path))
+	compiled, _ = store.GetCompiled("base")
+	_, _, found = compiled.WordAt([]rune("kay"), 0, 0)
+	assert.False(t, found)
+	_, _, found = compiled.WordAt([]rune("kii"), 0, 0)
+	assert.True(t, found)
+
+	// Reloading the keymap keeps the words of its dictionaries
+	assert.NoError(t, store.LoadKeymaps(writeKeymaps(t, map[string]string{"Base.aksj": baseKeymap})))
+	compiled, _ = store.GetCompiled("base")
+	_, _, found = compiled.WordAt([]rune("kii"), 0, 0)
+	assert.True(t, found)
+
+	// The keymaps a dictionary names must exist
+	if err := os.WriteFile(path, []byte(`{"keymap": "base", "reverse": "missing", "words": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := store.LoadDictionary(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "keymap with ID 'missing' not found")
+	}
+}
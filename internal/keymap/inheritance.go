@@ -0,0 +1,111 @@
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aks.go/internal/types"
+)
+
+// flattenAll resolves the inheritance chain of every keymap in sources and
+// returns the flattened keymaps by ID, together with the error of each keymap
+// that does not resolve. Keymaps that extend another are validated once
+// flattened. A keymap fails when it extends an unknown keymap, one that fails
+// or itself through a cycle; errors name the keymaps involved.
+func flattenAll(sources map[string]types.TransliterationScheme) (map[string]types.TransliterationScheme, map[string]error) {
+	flat := make(map[string]types.TransliterationScheme, len(sources))
+	errs := make(map[string]error)
+
+	var flatten func(id string, chain []string) (types.TransliterationScheme, error)
+	flatten = func(id string, chain []string) (types.TransliterationScheme, error) {
+		if scheme, done := flat[id]; done {
+			return scheme, nil
+		}
+		for i, visited := range chain {
+			if visited == id {
+				return types.TransliterationScheme{}, fmt.Errorf("keymap inheritance cycle: %s", strings.Join(append(chain[i:], id), " -> "))
+			}
+		}
+
+		source := sources[id]
+		if !source.IsOverlay() {
+			flat[id] = source
+			return source, nil
+		}
+		baseID, exists := resolveRef(sources, source.Extends)
+		if !exists {
+			return types.TransliterationScheme{}, fmt.Errorf("keymap '%s' extends unknown keymap '%s'", id, source.Extends)
+		}
+		base, err := flatten(baseID, append(chain, id))
+		if err != nil {
+			return types.TransliterationScheme{}, err
+		}
+		scheme, err := source.Flatten(base)
+		if err != nil {
+			return types.TransliterationScheme{}, err
+		}
+		if err := scheme.Validate(); err != nil {
+			return types.TransliterationScheme{}, fmt.Errorf("keymap validation failed for '%s': %w", id, err)
+		}
+		flat[id] = scheme
+		return scheme, nil
+	}
+
+	// Visit in sorted order so that errors do not depend on map iteration
+	ids := make([]string, 0, len(sources))
+	for id := range sources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, err := flatten(id, nil); err != nil {
+			errs[id] = err
+		}
+	}
+	return flat, errs
+}
+
+// firstError returns the error of the first keymap in ID order, or nil.
+func firstError(errs map[string]error) error {
+	if ids := sortedIDs(errs); len(ids) > 0 {
+		return errs[ids[0]]
+	}
+	return nil
+}
+
+// sortedIDs returns the keys of errs in order.
+func sortedIDs(errs map[string]error) []string {
+	ids := make([]string, 0, len(errs))
+	for id := range errs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// dependsOn reports whether the keymap id is one of changed or extends one of
+// them, directly or through other keymaps. A keymap that extends a bare ID
+// depends on every version of it, since adding or removing one can change
+// which is the latest. sources must be free of cycles.
+func dependsOn(sources map[string]types.TransliterationScheme, id string, changed map[string]bool) bool {
+	for {
+		if changed[id] {
+			return true
+		}
+		source := sources[id]
+		if !source.IsOverlay() {
+			return false
+		}
+		for key := range changed {
+			if refersTo(source.Extends, key) {
+				return true
+			}
+		}
+		baseID, exists := resolveRef(sources, source.Extends)
+		if !exists {
+			return false
+		}
+		id = baseID
+	}
+}
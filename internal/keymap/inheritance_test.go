@@ -0,0 +1,111 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aks.go/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKeymaps writes keymap files, by file name, to a new directory.
+func writeKeymaps(t *testing.T, files map[string]string) string {
+	t.Helper()
+	directory := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+const baseKeymap = `{
+	"id": "base", "name": "Base", "language": "Hindi", "scheme": "ITRANS",
+	"metadata": {"virama": "्, smart"},
+	"categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}, {"lhs": ["Y"], "rhs": ["य़"]}]}
+}`
+
+func TestLoadOverlays(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{
+		"Base.aksj":   baseKeymap,
+		"Middle.aksj": `{"id": "middle", "extends": "Base", "categories": {"consonants": [{"lhs": ["kh"], "rhs": ["ख"]}]}}`,
+		"Top.aksj":    `{"id": "top", "name": "Top", "extends": "middle", "remove": {"lhs": ["Y"]}, "categories": {}}`,
+		"Other.aksj":  `{"id": "other", "name": "Other", "language": "Hindi", "scheme": "ITRANS", "categories": {"digits": [{"lhs": ["1"], "rhs": ["१"]}]}}`,
+	})
+
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	top, found := store.GetKeymap("top")
+	if assert.True(t, found) {
+		assert.False(t, top.IsOverlay())
+		assert.Equal(t, "Top", top.Name)
+		section := top.Categories["consonants"]
+		assert.Len(t, section.Mappings.All(), 2, "k from base, kh from middle, Y removed")
+	}
+	_, found = store.GetCompiled("middle")
+	assert.True(t, found)
+
+	// Reloading the base recompiles the keymaps that extend it, and only those
+	before, _ := store.GetCompiled("top")
+	unrelated, _ := store.GetCompiled("other")
+	assert.NoError(t, store.LoadKeymaps(writeKeymaps(t, map[string]string{"Base.aksj": baseKeymap})))
+	after, _ := store.GetCompiled("top")
+	assert.NotSame(t, before, after)
+	again, _ := store.GetCompiled("other")
+	assert.Same(t, unrelated, again)
+}
+
+func TestLoadOverlayErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			"cycle",
+			map[string]string{
+				"A.aksj": `{"id": "a", "extends": "b", "categories": {}}`,
+				"B.aksj": `{"id": "b", "extends": "a", "categories": {}}`,
+			},
+			"keymap inheritance cycle: a -> b -> a",
+		},
+		{
+			"unknown base",
+			map[string]string{"A.aksj": `{"id": "a", "extends": "missing", "categories": {}}`},
+			"keymap 'a' extends unknown keymap 'missing'",
+		},
+		{
+			"invalid result",
+			map[string]string{
+				"Base.aksj": baseKeymap,
+				"A.aksj":    `{"id": "a", "extends": "base", "categories": {"vedic": [{"lhs": ["v"], "rhs": ["॑"]}]}}`,
+			},
+			"keymap validation failed for 'a': category 'vedic' in keymap 'a' has no role; declare one in the \"roles\" metadata",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewKeymapStore()
+			store.Strict = true
+			err := store.LoadKeymaps(writeKeymaps(t, test.files))
+			assert.EqualError(t, err, test.err)
+			assert.Empty(t, store.ListKeymapIDs(), "nothing is loaded in strict mode when a keymap fails")
+
+			// Otherwise the failing overlays are reported and the others loaded
+			store = NewKeymapStore()
+			assert.NoError(t, store.LoadKeymaps(writeKeymaps(t, test.files)))
+			report := store.Report()
+			if assert.NotEmpty(t, report.Files) {
+				assert.Equal(t, types.CheckExtends, report.Files[0].Errors[0].Check)
+				assert.Equal(t, test.err, report.Files[0].Errors[0].Message)
+			}
+			_, found := store.GetKeymap("a")
+			assert.False(t, found)
+		})
+	}
+}
@@ -18,8 +18,10 @@ package keymap
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -31,88 +33,472 @@ import (
 // and list all loaded keymap IDs. The store is thread-safe due to the use
 // of a read-write mutex.
 type KeymapStore struct {
-	// Maps keymap IDs to TransliterationScheme
+	// Maps keymap IDs to the latest version of their TransliterationScheme,
+	// with overlays flattened
 	Keymaps map[string]types.TransliterationScheme
+	// Maps versioned IDs to every version of every keymap, flattened
+	all map[string]types.TransliterationScheme
+	// Maps versioned IDs to the schemes as written, with overlays unflattened
+	sources map[string]types.TransliterationScheme
+	// Maps versioned IDs to their compiled, immutable lookup form
+	compiled map[string]*types.CompiledScheme
 	// Mutex for concurrent access
 	mu sync.RWMutex
+	// Serializes loads, each of which resolves every keymap again
+	loadMu sync.Mutex
+	// Dictionaries of whole-word overrides, in load order
+	dictionaries []dictionaryLayer
+	// Functions told which keymaps each load changed, see Subscribe
+	subscribers []func(ids []string)
+	// Keymaps as written that a removed keymap falls back to, see LoadDefaultKeymaps
+	defaults map[string]types.TransliterationScheme
+	// Files the keymaps were loaded from, by ID
+	files map[string]string
+	// Files that failed to load, by path, see Report
+	report map[string]FileReport
+
+	// Strict makes a load fail, and load nothing, when any file fails, instead
+	// of skipping the file and reporting it. Set it before loading.
+	Strict bool
 }
 
 // NewKeymapStore initializes a new KeymapStore with an empty map of keymaps.
 func NewKeymapStore() *KeymapStore {
 	return &KeymapStore{
-		Keymaps: make(map[string]types.TransliterationScheme),
+		Keymaps:  make(map[string]types.TransliterationScheme),
+		all:      make(map[string]types.TransliterationScheme),
+		sources:  make(map[string]types.TransliterationScheme),
+		compiled: make(map[string]*types.CompiledScheme),
 	}
 }
 
 // LoadKeymaps loads JSON keymaps from a specified directory into the store.
-// It reads all JSON files in the directory and adds them to the Keymaps map.
-// Returns an error if loading any keymap fails.
+// It reads all JSON files in the directory, resolves the keymaps they extend,
+// among them or among those loaded before, and adds them to the Keymaps map.
+// The dictionaries (.aksd files) of the directory are then loaded in name order.
+// By default, files that cannot be read or decoded, keymaps that fail to
+// validate or to resolve their base, and dictionaries that name a missing
+// keymap are skipped, keeping any version loaded before, and listed in
+// Report while the rest are loaded; an error is returned only if the
+// directory cannot be read. In Strict mode the first failure is returned
+// instead and leaves the store as it was.
 func (store *KeymapStore) LoadKeymaps(directory string) error {
-	files, err := os.ReadDir(directory)
+	return store.loadFS(os.DirFS(directory), directory)
+}
+
+// LoadKeymapsFS loads the keymaps and dictionaries at the root of fsys, such
+// as an embed.FS, like LoadKeymaps. Keymaps loaded later replace those with
+// the same ID and may extend them, so a directory loaded on top of fsys can
+// override or extend its keymaps.
+func (store *KeymapStore) LoadKeymapsFS(fsys fs.FS) error {
+	return store.loadFS(fsys, "")
+}
+
+// LoadDefaultKeymaps loads the keymaps of fsys like LoadKeymapsFS and keeps
+// them as the defaults: a keymap removed later, such as when a Watcher sees
+// its file deleted, falls back to the default with the same ID.
+func (store *KeymapStore) LoadDefaultKeymaps(fsys fs.FS) error {
+	if err := store.loadFS(fsys, ""); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.defaults = make(map[string]types.TransliterationScheme, len(store.sources))
+	for id, scheme := range store.sources {
+		store.defaults[id] = scheme
+	}
+	return nil
+}
+
+// loadFS loads the keymaps and dictionaries at the root of fsys all at once.
+// Files are named in errors and in the report by their path under directory.
+// Files that fail are skipped and reported, or fail the load in strict mode.
+func (store *KeymapStore) loadFS(fsys fs.FS, directory string) error {
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return fmt.Errorf("failed to read directory: %w", err)
 	}
 
+	var schemes []types.TransliterationScheme
+	var dictionaries []dictionaryLayer
+	var read []string
+	var failures []FileReport
+	origins := make(map[string]string)        // Files of the keymaps, by ID
+	dictionaryData := make(map[string][]byte) // Contents of the dictionary files, by path
 	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".aksj") {
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(file.Name(), ".aksd") {
+			filePath := filepath.Join(directory, file.Name())
+			read = append(read, filePath)
+			data, err := fs.ReadFile(fsys, file.Name())
+			var dictionary types.Dictionary
+			if err != nil {
+				err = fmt.Errorf("failed to read dictionary: %w", err)
+			} else {
+				dictionary, err = decodeDictionary(data, filePath)
+			}
+			if err != nil {
+				if store.Strict {
+					return err
+				}
+				failures = append(failures, dictionaryReport(filePath, data, err))
+				continue
+			}
+			dictionaries = append(dictionaries, dictionaryLayer{source: filePath, dictionary: dictionary})
+			dictionaryData[filePath] = data
+			continue
+		}
+		if !strings.HasSuffix(file.Name(), ".aksj") {
 			continue
 		}
 
 		filePath := filepath.Join(directory, file.Name())
-		if err := store.loadKeymapFromFile(filePath); err != nil {
-			return fmt.Errorf("failed to load keymap from file %s: %w", filePath, err)
+		read = append(read, filePath)
+		data, err := fs.ReadFile(fsys, file.Name())
+		var scheme types.TransliterationScheme
+		if err != nil {
+			err = fmt.Errorf("failed to read file: %w", err)
+		} else {
+			scheme, err = decodeKeymap(data, filePath)
+		}
+		if err != nil {
+			if store.Strict {
+				return fmt.Errorf("failed to load keymap from file %s: %w", filePath, err)
+			}
+			failures = append(failures, keymapReport(filePath, data, err))
+			continue
+		}
+		schemes = append(schemes, scheme)
+		origins[scheme.VersionedID()] = filePath
+	}
+
+	// The dictionaries are committed with the keymaps, so in strict mode a
+	// dictionary that fails to load leaves the store as it was
+	failed, err := store.update(schemes, nil, dictionaries, nil, store.Strict, origins)
+	if err != nil {
+		return err
+	}
+	for _, id := range sortedIDs(failed) {
+		if data, isDictionary := dictionaryData[id]; isDictionary {
+			failures = append(failures, dictionaryReport(id, data, failed[id]))
+			continue
 		}
+		failures = append(failures, FileReport{
+			File:   store.fileOf(id, origins),
+			ID:     id,
+			Errors: []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckExtends, Index: -1, Message: failed[id].Error()}},
+		})
 	}
 
+	store.setReport(read, failures)
 	return nil
 }
 
-// loadKeymapFromFile loads a single JSON keymap file into the store.
-// It reads the specified file and updates the Keymaps map with its contents.
+// readKeymapFile reads a single JSON keymap file. Keymaps that extend another
+// are validated once they are flattened, the others right away.
 // Returns an error if the file cannot be read or if the JSON is invalid.
-func (store *KeymapStore) loadKeymapFromFile(filePath string) error {
-	file, err := os.Open(filePath)
+func readKeymapFile(filePath string) (types.TransliterationScheme, error) {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return types.TransliterationScheme{}, fmt.Errorf("failed to read file: %w", err)
 	}
-	defer file.Close()
+	return decodeKeymap(data, filePath)
+}
 
+// decodeKeymap decodes and validates the keymap read from filePath.
+func decodeKeymap(data []byte, filePath string) (types.TransliterationScheme, error) {
 	var scheme types.TransliterationScheme
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&scheme); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
+	if err := json.Unmarshal(data, &scheme); err != nil {
+		return types.TransliterationScheme{}, fmt.Errorf("failed to decode JSON: %w", err)
 	}
 
 	// Validate the scheme
-	if err := scheme.Validate(); err != nil {
-		return fmt.Errorf("keymap validation failed for '%s': %w", filePath, err)
+	if scheme.IsOverlay() {
+		if scheme.ID == "" {
+			return types.TransliterationScheme{}, fmt.Errorf("keymap extending '%s' has no id", scheme.Extends)
+		}
+	} else if err := scheme.Validate(); err != nil {
+		return types.TransliterationScheme{}, fmt.Errorf("keymap validation failed for '%s': %w", filePath, err)
+	}
+	return scheme, nil
+}
+
+// update stores schemes as written, loaded from the files of origins, and
+// drops the keymaps with the removed versioned IDs. It layers the dictionaries of layers
+// on top of the others and drops those loaded from the dropped files. It then
+// flattens every keymap again and compiles the ones whose flattened form or
+// words can have changed. In strict mode the store is updated only if every
+// keymap resolves and every dictionary names loaded keymaps. Otherwise a
+// keymap that does not resolve keeps its previous version, if that still
+// resolves, or is dropped, and its error is returned by ID; a dictionary that
+// names a missing keymap is left out, and its error is returned by file. The
+// store is updated all at once and subscribers are told what changed.
+func (store *KeymapStore) update(schemes []types.TransliterationScheme, removed []string, layers []dictionaryLayer, dropped []string, strict bool, origins map[string]string) (map[string]error, error) {
+	store.loadMu.Lock()
+	defer store.loadMu.Unlock()
+
+	store.mu.RLock()
+	sources := make(map[string]types.TransliterationScheme, len(store.sources)+len(schemes))
+	for id, scheme := range store.sources {
+		sources[id] = scheme
+	}
+	oldCompiled := store.compiled
+	dictionaries := store.dictionaries
+	defaults := store.defaults
+	store.mu.RUnlock()
+
+	changed := make(map[string]bool)
+	for _, id := range removed {
+		if key, exists := findID(sources, id); exists {
+			delete(sources, key)
+			changed[key] = true
+		}
+		if key, exists := findID(defaults, id); exists {
+			sources[key] = defaults[key]
+			changed[key] = true
+		}
+	}
+	for _, scheme := range schemes {
+		// A keymap replaces the one of the same ID and version in any letter
+		// case, and is kept alongside its other versions
+		if key, exists := findID(sources, scheme.VersionedID()); exists {
+			delete(sources, key)
+			changed[key] = true
+		}
+		sources[scheme.VersionedID()] = scheme
+		changed[scheme.VersionedID()] = true
+	}
+	flat, errs := flattenAll(sources)
+	if strict && len(errs) > 0 {
+		return nil, firstError(errs)
+	}
+	failed := make(map[string]error)
+	reverted := make(map[string]bool)
+	for len(errs) > 0 {
+		for id, err := range errs {
+			if _, seen := failed[id]; !seen {
+				failed[id] = err
+			}
+			if previous, existed := store.sources[id]; existed && !reverted[id] {
+				sources[id] = previous
+				reverted[id] = true
+			} else {
+				delete(sources, id)
+			}
+			changed[id] = true
+		}
+		flat, errs = flattenAll(sources)
+	}
+
+	// Dictionaries name keymaps, so they are checked against the keymaps as updated
+	var accepted []dictionaryLayer
+	for _, layer := range layers {
+		if err := checkDictionary(flat, layer); err != nil {
+			if strict {
+				return nil, err
+			}
+			failed[layer.source] = err
+			continue
+		}
+		accepted = append(accepted, layer)
+	}
+	dictionaries, changedLayers := layerDictionaries(dictionaries, accepted, dropped)
+
+	// Compile outside the lock; the results are immutable once built
+	compiled := make(map[string]*types.CompiledScheme, len(flat))
+	for id, scheme := range flat {
+		existing, exists := oldCompiled[id]
+		switch {
+		case !exists || dependsOn(sources, id, changed):
+			compiled[id] = types.CompileScheme(scheme).WithWords(wordLayers(dictionaries, id)...)
+		case namesKeymap(changedLayers, id):
+			// Compiled schemes are shared, so the keymap gets a new one
+			compiled[id] = existing.WithWords(wordLayers(dictionaries, id)...)
+		default:
+			compiled[id] = existing
+		}
 	}
 
+	store.mu.Lock()
+	store.sources = sources
+	store.dictionaries = dictionaries
+	store.all = flat
+	store.Keymaps = latestVersions(flat)
+	store.compiled = compiled
+	if store.files == nil {
+		store.files = make(map[string]string)
+	}
+	for id, file := range origins {
+		if _, failed := failed[id]; !failed {
+			store.files[id] = file
+		}
+	}
+	store.mu.Unlock()
+
+	store.notify(changedIDs(oldCompiled, compiled))
+	return failed, nil
+}
+
+// fileOf returns the file the keymap id comes from, in origins or, failing
+// that, among the files loaded before.
+func (store *KeymapStore) fileOf(id string, origins map[string]string) string {
+	if file, exists := origins[id]; exists {
+		return file
+	}
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.files[id]
+}
+
+// Subscribe registers fn to be told the versioned IDs of the keymaps that each
+// later load adds, changes or removes, including those that extend a changed keymap
+// or whose dictionary words changed, so that anything derived from them can be
+// invalidated. fn runs on the goroutine that loaded the keymaps, once the new
+// ones are in place, and must not load keymaps itself.
+func (store *KeymapStore) Subscribe(fn func(ids []string)) {
 	store.mu.Lock()
 	defer store.mu.Unlock()
-	store.Keymaps[scheme.ID] = scheme
-	return nil
+	store.subscribers = append(store.subscribers, fn)
+}
+
+// notify tells the subscribers the IDs of the changed keymaps, if any.
+func (store *KeymapStore) notify(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	store.mu.RLock()
+	subscribers := store.subscribers
+	store.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(ids)
+	}
+}
+
+// changedIDs returns, sorted, the IDs whose compiled keymap differs between
+// before and after, including those only one of them has.
+func changedIDs(before, after map[string]*types.CompiledScheme) []string {
+	var ids []string
+	for id, compiled := range after {
+		if before[id] != compiled {
+			ids = append(ids, id)
+		}
+	}
+	for id := range before {
+		if _, exists := after[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// LintFile decodes the keymap file at filePath without validating it and
+// returns the problems types.TransliterationScheme.Lint finds in it. An
+// overlay is flattened with the keymap it extends, from the same directory,
+// and mapping indexes refer to the flattened keymap. It returns an error only
+// if the file cannot be read or is not valid JSON.
+func LintFile(filePath string) ([]types.Diagnostic, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var scheme types.TransliterationScheme
+	if err := json.Unmarshal(data, &scheme); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if scheme.IsOverlay() {
+		flat, err := flattenFromDirectory(scheme, filepath.Dir(filePath))
+		if err != nil {
+			return []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckExtends, Index: -1, Message: err.Error()}}, nil
+		}
+		scheme = flat
+	}
+	return scheme.Lint(), nil
+}
+
+// flattenFromDirectory flattens an overlay with the keymap it extends, loaded
+// with the other keymaps of directory.
+func flattenFromDirectory(overlay types.TransliterationScheme, directory string) (types.TransliterationScheme, error) {
+	store := NewKeymapStore()
+	if err := store.LoadKeymaps(directory); err != nil {
+		return types.TransliterationScheme{}, fmt.Errorf("cannot load base keymap '%s': %w", overlay.Extends, err)
+	}
+	base, found := store.GetKeymap(overlay.Extends)
+	if !found {
+		return types.TransliterationScheme{}, fmt.Errorf("keymap '%s' extends unknown keymap '%s'", overlay.ID, overlay.Extends)
+	}
+	return overlay.Flatten(base)
 }
 
-// GetKeymap retrieves a TransliterationScheme by ID.
+// GetKeymap retrieves a TransliterationScheme by ID, in its latest version,
+// or by "id@version" in that version; the scheme tells which version it is.
 // Returns the TransliterationScheme and a boolean indicating whether it was found.
 // The ID comparison is case-insensitive.
 func (store *KeymapStore) GetKeymap(id string) (types.TransliterationScheme, bool) {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
-	
+
+	if key, ok := store.resolveID(id); ok {
+		return store.all[key], true
+	}
+	return types.TransliterationScheme{}, false
+}
+
+// GetCompiled retrieves the compiled form of a keymap by ID or "id@version",
+// like GetKeymap. The returned CompiledScheme is shared and must be treated
+// as read-only. The ID comparison is case-insensitive.
+func (store *KeymapStore) GetCompiled(id string) (*types.CompiledScheme, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if key, ok := store.resolveID(id); ok {
+		if compiled, exists := store.compiled[key]; exists {
+			return compiled, true
+		}
+	}
+	return nil, false
+}
+
+// resolveID finds the versioned ID of the keymap id names, case-insensitively.
+// The caller must hold at least a read lock.
+func (store *KeymapStore) resolveID(id string) (string, bool) {
+	return resolveRef(store.all, id)
+}
+
+// latestVersions returns the latest version of each keymap of all, by ID.
+func latestVersions(all map[string]types.TransliterationScheme) map[string]types.TransliterationScheme {
+	latest := make(map[string]types.TransliterationScheme)
+	for _, scheme := range all {
+		if key, _ := resolveRef(all, scheme.ID); key == scheme.VersionedID() {
+			latest[scheme.ID] = scheme
+		}
+	}
+	return latest
+}
+
+// findID finds the key of keymaps matching id case-insensitively.
+func findID[V any](keymaps map[string]V, id string) (string, bool) {
+	if _, exists := keymaps[id]; exists {
+		return id, true
+	}
+
 	// Convert the input ID to lowercase for case-insensitive comparison
 	lowerID := strings.ToLower(id)
-	for k, v := range store.Keymaps {
+	for k := range keymaps {
 		if strings.ToLower(k) == lowerID {
-			return v, true
+			return k, true
 		}
 	}
-	return types.TransliterationScheme{}, false
+	return "", false
 }
 
-// ListKeymapIDs returns a list of all loaded keymap IDs.
-// This is useful for iterating over available keymaps.
+// ListKeymapIDs returns a list of all loaded keymap IDs, once for all their
+// versions. This is useful for iterating over available keymaps.
 func (store *KeymapStore) ListKeymapIDs() []string {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
@@ -124,3 +510,18 @@ func (store *KeymapStore) ListKeymapIDs() []string {
 
 	return ids
 }
+
+// Warnings returns the validation warnings of the loaded keymaps that have any,
+// by keymap ID. Warnings do not stop a keymap from loading.
+func (store *KeymapStore) Warnings() map[string][]string {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	warnings := make(map[string][]string)
+	for id, scheme := range store.Keymaps {
+		if list := scheme.Warnings(); len(list) > 0 {
+			warnings[id] = list
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,133 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"aks.go/internal/types"
+	"aks.go/keymaps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKeymapsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Base.aksj":   {Data: []byte(baseKeymap)},
+		"Base.aksd":   {Data: []byte(`{"keymap": "base", "words": [{"lhs": ["kay"], "rhs": ["के"]}]}`)},
+		"notes.txt":   {Data: []byte("not a keymap")},
+		"sub/X.aksj":  {Data: []byte("not loaded")},
+		"Other.aksj":  {Data: []byte(`{"id": "other", "name": "Other", "language": "Hindi", "scheme": "ITRANS", "categories": {"digits": [{"lhs": ["1"], "rhs": ["१"]}]}}`)},
+		"Middle.aksj": {Data: []byte(`{"id": "middle", "extends": "base", "categories": {"consonants": [{"lhs": ["kh"], "rhs": ["ख"]}]}}`)},
+	}
+
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymapsFS(fsys)) {
+		return
+	}
+	assert.ElementsMatch(t, []string{"base", "middle", "other"}, store.ListKeymapIDs())
+	compiled, _ := store.GetCompiled("base")
+	_, _, found := compiled.WordAt([]rune("kay"), 0, 0)
+	assert.True(t, found)
+
+	// A directory loaded on top overrides and extends the keymaps of the FS
+	directory := writeKeymaps(t, map[string]string{
+		"Other.aksj": `{"id": "Other", "name": "Other on disk", "language": "Hindi", "scheme": "ITRANS", "categories": {"digits": [{"lhs": ["2"], "rhs": ["२"]}]}}`,
+		"Top.aksj":   `{"id": "top", "name": "Top", "extends": "middle", "categories": {}}`,
+	})
+	assert.NoError(t, store.LoadKeymaps(directory))
+	assert.ElementsMatch(t, []string{"base", "middle", "Other", "top"}, store.ListKeymapIDs())
+	other, _ := store.GetKeymap("other")
+	assert.Equal(t, "Other on disk", other.Name)
+
+	fsys["Broken.aksj"] = &fstest.MapFile{Data: []byte("{")}
+	strict := NewKeymapStore()
+	strict.Strict = true
+	err := strict.LoadKeymapsFS(fsys)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to load keymap from file Broken.aksj")
+	}
+
+	// In strict mode a dictionary that fails to load keeps the keymaps from loading
+	delete(fsys, "Broken.aksj")
+	fsys["Words.aksd"] = &fstest.MapFile{Data: []byte(`{"keymap": "missing", "words": []}`)}
+	err = strict.LoadKeymapsFS(fsys)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to load dictionary Words.aksd: keymap with ID 'missing' not found")
+	}
+	assert.Empty(t, strict.ListKeymapIDs())
+	fsys["Words.aksd"] = &fstest.MapFile{Data: []byte(`{"keymap": "base", "words": [`)}
+	assert.Error(t, strict.LoadKeymapsFS(fsys))
+	assert.Empty(t, strict.ListKeymapIDs())
+}
+
+func TestLoadReport(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{
+		"Base.aksj":    baseKeymap,
+		"Broken.aksj":  `{"id": "broken",`,
+		"Invalid.aksj": `{"id": "invalid", "name": "Invalid", "language": "Hindi", "scheme": "ITRANS", "metadata": {"virama": "्, smart"}, "categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}, {"lhs": ["g"], "rhs": []}]}}`,
+		"Orphan.aksj":  `{"id": "orphan", "extends": "missing", "categories": {}}`,
+		"Words.aksd":   `{"keymap": "missing", "words": []}`,
+	})
+
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	assert.Equal(t, []string{"base"}, store.ListKeymapIDs(), "the valid keymaps load")
+
+	report := store.Report()
+	assert.False(t, report.OK())
+	if !assert.Len(t, report.Files, 4) {
+		return
+	}
+	broken, invalid, orphan, words := report.Files[0], report.Files[1], report.Files[2], report.Files[3]
+	assert.Equal(t, filepath.Join(directory, "Broken.aksj"), broken.File)
+	assert.Empty(t, broken.ID)
+	assert.Equal(t, types.CheckDecode, broken.Errors[0].Check)
+
+	assert.Equal(t, "invalid", invalid.ID)
+	assert.Equal(t, []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckMapping, Category: "consonants", Index: 1, Message: "the mapping has no RHS"}}, invalid.Errors)
+	assert.Equal(t, filepath.Join(directory, "Invalid.aksj")+" (invalid): error: category 'consonants' mapping 1: the mapping has no RHS", invalid.String())
+
+	assert.Equal(t, "orphan", orphan.ID)
+	assert.Equal(t, "keymap 'orphan' extends unknown keymap 'missing'", orphan.Errors[0].Message)
+	assert.Equal(t, "missing", words.ID)
+
+	// Fixing a file and loading it again clears it from the report
+	rewrite(t, filepath.Join(directory, "Broken.aksj"), `{"id": "broken", "extends": "base", "categories": {}}`)
+	assert.NoError(t, store.LoadKeymaps(directory))
+	assert.Len(t, store.Report().Files, 3)
+	_, found := store.GetKeymap("broken")
+	assert.True(t, found)
+}
+
+func TestLoadDefaultKeymaps(t *testing.T) {
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadDefaultKeymaps(keymaps.FS)) {
+		return
+	}
+	_, found := store.GetCompiled("marathi")
+	assert.True(t, found, "the shipped keymaps are embedded")
+
+	// Removing an override falls back to the embedded keymap
+	directory := writeKeymaps(t, map[string]string{
+		"IAST.aksj": `{"id": "iast", "version": "2025.1", "name": "IAST on disk", "language": "Devanagari", "scheme": "IAST", "categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}]}}`,
+	})
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	iast, _ := store.GetKeymap("iast")
+	assert.Equal(t, "IAST on disk", iast.Name)
+
+	watcher, err := store.Watch(directory, time.Hour, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer watcher.Stop()
+	assert.NoError(t, os.Remove(filepath.Join(directory, "IAST.aksj")))
+	assert.NoError(t, watcher.Poll())
+	iast, _ = store.GetKeymap("iast")
+	assert.Equal(t, "IAST Transliteration Scheme", iast.Name)
+}
@@ -0,0 +1,106 @@
+package keymap
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"aks.go/internal/types"
+)
+
+// FileReport lists the errors that kept a keymap or dictionary file from
+// loading, each with the category and mapping index it concerns, if any.
+type FileReport struct {
+	File   string             `json:"file"`
+	ID     string             `json:"id,omitempty"` // The keymap ID, when the file could be decoded
+	Errors []types.Diagnostic `json:"errors"`
+}
+
+// String returns the file, its keymap ID and its errors on one line.
+func (r FileReport) String() string {
+	name := r.File
+	if r.ID != "" {
+		name += " (" + r.ID + ")"
+	}
+	messages := make([]string, 0, len(r.Errors))
+	for _, diagnostic := range r.Errors {
+		messages = append(messages, diagnostic.String())
+	}
+	return name + ": " + strings.Join(messages, "; ")
+}
+
+// LoadReport lists the files the loads of a store skipped, in file order.
+type LoadReport struct {
+	Files []FileReport `json:"files"`
+}
+
+// OK reports whether every file loaded.
+func (r LoadReport) OK() bool {
+	return len(r.Files) == 0
+}
+
+// Report returns the files that failed to load, as of the latest load of
+// each. A file that loads later, or is fixed and reloaded, leaves the report.
+// In strict mode loads fail instead and the report stays empty.
+func (store *KeymapStore) Report() LoadReport {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	report := LoadReport{Files: make([]FileReport, 0, len(store.report))}
+	for _, file := range store.report {
+		report.Files = append(report.Files, file)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].File < report.Files[j].File })
+	return report
+}
+
+// setReport replaces the report entries of the files a load read with the
+// failures among them.
+func (store *KeymapStore) setReport(files []string, failures []FileReport) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.report == nil {
+		store.report = make(map[string]FileReport)
+	}
+	for _, file := range files {
+		delete(store.report, file)
+	}
+	for _, failure := range failures {
+		store.report[failure.File] = failure
+	}
+}
+
+// keymapReport describes why the keymap file could not be loaded. A keymap
+// that fails validation is reported with the errors Lint finds in it, which
+// locate them by category and mapping; err stands in when Lint finds none.
+func keymapReport(file string, data []byte, err error) FileReport {
+	report := FileReport{File: file}
+	var scheme types.TransliterationScheme
+	if json.Unmarshal(data, &scheme) != nil {
+		report.Errors = []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckDecode, Index: -1, Message: err.Error()}}
+		return report
+	}
+
+	report.ID = scheme.ID
+	if !scheme.IsOverlay() {
+		for _, diagnostic := range scheme.Lint() {
+			if diagnostic.Severity == types.SeverityError {
+				report.Errors = append(report.Errors, diagnostic)
+			}
+		}
+	}
+	if len(report.Errors) == 0 {
+		report.Errors = []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckValidation, Index: -1, Message: err.Error()}}
+	}
+	return report
+}
+
+// dictionaryReport describes why the dictionary file could not be loaded.
+func dictionaryReport(file string, data []byte, err error) FileReport {
+	var dictionary types.Dictionary
+	if json.Unmarshal(data, &dictionary) != nil {
+		return FileReport{File: file, Errors: []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckDecode, Index: -1, Message: err.Error()}}}
+	}
+	return FileReport{File: file, ID: dictionary.Keymap, Errors: []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckValidation, Index: -1, Message: err.Error()}}}
+}
@@ -0,0 +1,94 @@
+package keymap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keymaps are stored under their versioned ID, "id@version", or their bare ID
+// when they have no version, so that several versions of a keymap can be
+// loaded side by side. A reference to a keymap is either form: a bare ID
+// names the latest version.
+
+// resolveRef finds the key of keymaps that ref names: the exact version for
+// "id@version", the latest version of the ID otherwise. IDs and versions are
+// compared case-insensitively.
+func resolveRef[V any](keymaps map[string]V, ref string) (string, bool) {
+	if strings.Contains(ref, "@") {
+		return findID(keymaps, ref)
+	}
+
+	latest, found := "", false
+	for key := range keymaps {
+		id, version, _ := strings.Cut(key, "@")
+		if !strings.EqualFold(id, ref) {
+			continue
+		}
+		_, latestVersion, _ := strings.Cut(latest, "@")
+		if !found || compareVersions(version, latestVersion) > 0 || (compareVersions(version, latestVersion) == 0 && key < latest) {
+			latest, found = key, true
+		}
+	}
+	return latest, found
+}
+
+// refersTo reports whether ref names the keymap stored under key, in any
+// version for a bare ID.
+func refersTo(ref, key string) bool {
+	if strings.Contains(ref, "@") {
+		return strings.EqualFold(ref, key)
+	}
+	id, _, _ := strings.Cut(key, "@")
+	return strings.EqualFold(id, ref)
+}
+
+// compareVersions compares two versions such as "2025.1" and "2025.10" part by
+// part, numerically where both parts are numbers, and returns -1, 0 or 1. A
+// version that is a prefix of the other is the earlier one, so no version at
+// all comes first.
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.FieldsFunc(a, isVersionSeparator), strings.FieldsFunc(b, isVersionSeparator)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numberA, errA := strconv.Atoi(partsA[i])
+		numberB, errB := strconv.Atoi(partsB[i])
+		switch {
+		case errA == nil && errB == nil && numberA != numberB:
+			if numberA < numberB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && partsA[i] != partsB[i]:
+			return strings.Compare(partsA[i], partsB[i])
+		}
+	}
+	switch {
+	case len(partsA) < len(partsB):
+		return -1
+	case len(partsA) > len(partsB):
+		return 1
+	}
+	return 0
+}
+
+// isVersionSeparator reports whether r separates the parts of a version.
+func isVersionSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '_'
+}
+
+// ListVersions returns the versions of the keymap with the given ID, earliest
+// first. A keymap without a version is listed as "".
+func (store *KeymapStore) ListVersions(id string) []string {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var versions []string
+	for key := range store.all {
+		if refersTo(id, key) && !strings.Contains(id, "@") {
+			_, version, _ := strings.Cut(key, "@")
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) < 0 })
+	return versions
+}
@@ -0,0 +1,99 @@
+package keymap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2025.1", "2025.1", 0},
+		{"2025.10", "2025.9", 1},
+		{"2024.12", "2025.1", -1},
+		{"2025.1", "2025.1.1", -1},
+		{"", "1", -1},
+		{"2025-beta", "2025-alpha", 1},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, compareVersions(test.a, test.b), "%q vs %q", test.a, test.b)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{
+		"Base-2024.aksj": `{
+			"version": "2024.9", "id": "base", "name": "Base", "language": "Hindi", "scheme": "ITRANS",
+			"metadata": {"virama": "्, smart"},
+			"categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}]}
+		}`,
+		"Base-2025.aksj": `{
+			"version": "2025.10", "id": "base", "name": "Base", "language": "Hindi", "scheme": "ITRANS",
+			"metadata": {"virama": "्, smart"},
+			"categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}, {"lhs": ["g"], "rhs": ["ग"]}]}
+		}`,
+		"Pinned.aksj":   `{"version": "1", "id": "pinned", "extends": "base@2024.9", "categories": {}}`,
+		"Follower.aksj": `{"id": "follower", "extends": "base", "categories": {}}`,
+	})
+
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	assert.Equal(t, []string{"2024.9", "2025.10"}, store.ListVersions("base"))
+	assert.ElementsMatch(t, []string{"base", "follower", "pinned"}, store.ListKeymapIDs(), "IDs are listed once")
+
+	// A bare ID resolves to the latest version
+	latest, found := store.GetKeymap("base")
+	if assert.True(t, found) {
+		assert.Equal(t, "2025.10", latest.Version)
+	}
+	older, found := store.GetKeymap("Base@2024.9")
+	if assert.True(t, found) {
+		assert.Equal(t, "2024.9", older.Version)
+	}
+	_, found = store.GetCompiled("base@2023.1")
+	assert.False(t, found)
+
+	// Overlays extend the version they name
+	pinned, _ := store.GetKeymap("pinned@1")
+	section := pinned.Categories["consonants"]
+	assert.Len(t, section.Mappings.All(), 1)
+	assert.Equal(t, "base@2024.9", pinned.Base)
+
+	// A bare ID follows the latest version of the base, and the overlay is
+	// rebuilt when a later version is loaded or the latest is removed
+	follower, _ := store.GetKeymap("follower")
+	assert.Equal(t, "base@2025.10", follower.Base)
+	newer := writeKeymaps(t, map[string]string{
+		"Base-2026.aksj": `{
+			"version": "2026.1", "id": "base", "name": "Base", "language": "Hindi", "scheme": "ITRANS",
+			"metadata": {"virama": "्, smart"},
+			"categories": {"consonants": [{"lhs": ["k"], "rhs": ["ख"]}]}
+		}`,
+	})
+	assert.NoError(t, store.LoadKeymaps(newer))
+	latest, _ = store.GetKeymap("base")
+	assert.Equal(t, "2026.1", latest.Version)
+	follower, _ = store.GetKeymap("follower")
+	assert.Equal(t, "base@2026.1", follower.Base)
+	section = follower.Categories["consonants"]
+	assert.Len(t, section.Mappings.All(), 1)
+	compiled, _ := store.GetCompiled("follower")
+	assert.Equal(t, "base@2026.1", compiled.Scheme.Base)
+
+	_, err := store.update(nil, []string{"base@2026.1"}, nil, nil, true, nil)
+	assert.NoError(t, err)
+	compiled, _ = store.GetCompiled("follower")
+	assert.Equal(t, "base@2025.10", compiled.Scheme.Base)
+
+	// The latest version wins whatever order the versions were loaded in
+	reversed := NewKeymapStore()
+	assert.NoError(t, reversed.LoadKeymaps(newer))
+	assert.NoError(t, reversed.LoadKeymaps(directory))
+	follower, _ = reversed.GetKeymap("follower")
+	assert.Equal(t, "base@2026.1", follower.Base)
+}
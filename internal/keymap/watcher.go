@@ -0,0 +1,286 @@
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"aks.go/internal/types"
+)
+
+// fileStamp tells whether a file changed between two polls.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher polls a keymap directory and reloads the keymaps and dictionaries
+// of the files added, changed or removed since the previous poll. Polling
+// needs no platform support and is cheap at the size of a keymap directory.
+type Watcher struct {
+	store     *KeymapStore
+	directory string
+	onError   func(error)
+
+	mu     sync.Mutex           // Serializes polls
+	stamps map[string]fileStamp // Files seen by the latest poll, by path
+	ids    map[string]string    // Versioned IDs of the keymaps loaded from each file, by path
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Watch starts polling directory, whose keymaps must already be loaded with
+// LoadKeymaps, every interval. A keymap file and the dictionary of the same
+// name, such as Hindi.aksj and Hindi.aksd, are validated with the keymaps
+// already loaded and swapped in together, or not at all: files that fail
+// leave the previous keymap and words in place and are listed by Report until
+// they load, without holding back the other files changed with them. Failures
+// to read the directory are reported to onError, which may be nil.
+// Subscribers of the store are told which keymaps changed. Call Stop to stop
+// polling.
+func (store *KeymapStore) Watch(directory string, interval time.Duration, onError func(error)) (*Watcher, error) {
+	w := &Watcher{
+		store:     store,
+		directory: directory,
+		onError:   onError,
+		ids:       make(map[string]string),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	stamps, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.stamps = stamps
+	for path := range stamps {
+		if id := keymapID(path); id != "" {
+			w.ids[path] = id
+		}
+	}
+
+	go w.run(interval)
+	return w, nil
+}
+
+// Stop stops polling and waits for a reload in progress to finish.
+func (w *Watcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// run polls every interval until stopped.
+func (w *Watcher) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Poll(); err != nil && w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// Poll checks the directory once and reloads what changed, without waiting
+// for the next interval.
+func (w *Watcher) Poll() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stamps, err := w.scan()
+	if err != nil {
+		return err
+	}
+	groups := make(map[string]bool) // Paths without extension of the changed files
+	for path, stamp := range stamps {
+		if old, seen := w.stamps[path]; !seen || old != stamp {
+			groups[strings.TrimSuffix(path, filepath.Ext(path))] = true
+		}
+	}
+	for path := range w.stamps {
+		if _, exists := stamps[path]; !exists {
+			groups[strings.TrimSuffix(path, filepath.Ext(path))] = true
+		}
+	}
+	w.stamps = stamps
+
+	stems := make([]string, 0, len(groups))
+	for stem := range groups {
+		stems = append(stems, stem)
+	}
+	sort.Strings(stems)
+	w.reload(stems, stamps)
+	return nil
+}
+
+// reload reloads the keymap file and dictionary of each stem, a group at a
+// time, and reports the files of the groups that fail. A group that fails is
+// tried again once another has loaded, since it may extend a keymap loaded
+// with it.
+func (w *Watcher) reload(stems []string, stamps map[string]fileStamp) {
+	var files []string
+	failures := make(map[string][]FileReport)
+	for progress := true; progress && len(stems) > 0; {
+		progress = false
+		var failed []string
+		for _, stem := range stems {
+			reports := w.reloadGroup(stem, stamps)
+			if len(reports) > 0 {
+				failures[stem] = reports
+				failed = append(failed, stem)
+				continue
+			}
+			delete(failures, stem)
+			files = append(files, stem+".aksj", stem+".aksd")
+			progress = true
+		}
+		stems = failed
+	}
+
+	var reports []FileReport
+	for _, stem := range stems {
+		files = append(files, stem+".aksj", stem+".aksd")
+		reports = append(reports, failures[stem]...)
+	}
+	w.store.setReport(files, reports)
+}
+
+// reloadGroup loads the keymap file and dictionary of stem as they are in
+// stamps, dropping those that are gone, and returns the reports of the files
+// that kept them from loading.
+func (w *Watcher) reloadGroup(stem string, stamps map[string]fileStamp) []FileReport {
+	keymapPath, dictionaryPath := stem+".aksj", stem+".aksd"
+	ids := make(map[string]string, len(w.ids))
+	for path, id := range w.ids {
+		ids[path] = id
+	}
+
+	var schemes []types.TransliterationScheme
+	var dictionaries []dictionaryLayer
+	var keymapData, dictionaryData []byte
+	var reports []FileReport
+	origins := make(map[string]string)
+	delete(ids, keymapPath)
+	if _, exists := stamps[keymapPath]; exists {
+		data, err := os.ReadFile(keymapPath)
+		var scheme types.TransliterationScheme
+		if err != nil {
+			err = fmt.Errorf("failed to read file: %w", err)
+		} else {
+			scheme, err = decodeKeymap(data, keymapPath)
+		}
+		if err != nil {
+			reports = append(reports, keymapReport(keymapPath, data, fmt.Errorf("failed to load keymap from file %s: %w", keymapPath, err)))
+		} else {
+			schemes = append(schemes, scheme)
+			ids[keymapPath] = scheme.VersionedID()
+			origins[scheme.VersionedID()] = keymapPath
+		}
+		keymapData = data
+	}
+	if _, exists := stamps[dictionaryPath]; exists {
+		data, err := os.ReadFile(dictionaryPath)
+		var dictionary types.Dictionary
+		if err != nil {
+			err = fmt.Errorf("failed to read dictionary: %w", err)
+		} else {
+			dictionary, err = decodeDictionary(data, dictionaryPath)
+		}
+		if err != nil {
+			reports = append(reports, dictionaryReport(dictionaryPath, data, err))
+		} else {
+			dictionaries = append(dictionaries, dictionaryLayer{source: dictionaryPath, dictionary: dictionary})
+		}
+		dictionaryData = data
+	}
+	if len(reports) > 0 {
+		return reports
+	}
+
+	// A keymap is gone when no file defines its ID any more
+	var gone []string
+	if id, loaded := w.ids[keymapPath]; loaded && !containsID(ids, id) {
+		gone = append(gone, id)
+	}
+	var dropped []string
+	if _, exists := stamps[dictionaryPath]; !exists {
+		dropped = append(dropped, dictionaryPath)
+	}
+	if _, err := w.store.update(schemes, gone, dictionaries, dropped, true, origins); err != nil {
+		if _, exists := stamps[keymapPath]; exists {
+			reports = append(reports, keymapReport(keymapPath, keymapData, err))
+		}
+		if _, exists := stamps[dictionaryPath]; exists {
+			reports = append(reports, dictionaryReport(dictionaryPath, dictionaryData, err))
+		}
+		if len(reports) == 0 {
+			reports = append(reports, FileReport{File: keymapPath, Errors: []types.Diagnostic{{Severity: types.SeverityError, Check: types.CheckExtends, Index: -1, Message: err.Error()}}})
+		}
+		return reports
+	}
+	w.ids = ids
+	return nil
+}
+
+// scan returns the stamps of the keymap and dictionary files of the directory.
+func (w *Watcher) scan() (map[string]fileStamp, error) {
+	files, err := os.ReadDir(w.directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	stamps := make(map[string]fileStamp)
+	for _, file := range files {
+		if file.IsDir() || !(strings.HasSuffix(file.Name(), ".aksj") || strings.HasSuffix(file.Name(), ".aksd")) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		stamps[filepath.Join(w.directory, file.Name())] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// keymapID returns the versioned ID of the keymap file at path, or "" if it
+// has no ID or is not a keymap file.
+func keymapID(path string) string {
+	if !strings.HasSuffix(path, ".aksj") {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var header struct {
+		ID      string `json:"id"`
+		Version string `json:"version"`
+	}
+	if json.Unmarshal(data, &header) != nil || header.ID == "" {
+		return ""
+	}
+	scheme := types.TransliterationScheme{ID: header.ID, Version: header.Version}
+	return scheme.VersionedID()
+}
+
+// containsID reports whether ids holds id, compared case-insensitively.
+func containsID(ids map[string]string, id string) bool {
+	for _, other := range ids {
+		if strings.EqualFold(other, id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rewrite writes content to the file at path and moves its modification time
+// forward, so that a poll sees the change however coarse the file system clock.
+func rewrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{
+		"Base.aksj": baseKeymap,
+		"Top.aksj":  `{"id": "top", "extends": "base", "categories": {"consonants": [{"lhs": ["kh"], "rhs": ["ख"]}]}}`,
+	})
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+
+	var mu sync.Mutex
+	var changes [][]string
+	store.Subscribe(func(ids []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, ids)
+	})
+	watcher, err := store.Watch(directory, time.Hour, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer watcher.Stop()
+
+	// A changed keymap is reloaded together with the keymaps extending it
+	assert.NoError(t, watcher.Poll())
+	assert.Empty(t, changes, "nothing changed yet")
+	rewrite(t, filepath.Join(directory, "Base.aksj"), `{
+		"id": "base", "name": "Base", "language": "Hindi", "scheme": "ITRANS",
+		"categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}, {"lhs": ["g"], "rhs": ["ग"]}, {"lhs": ["Y"], "rhs": ["य़"]}]}
+	}`)
+	assert.NoError(t, watcher.Poll())
+	assert.Equal(t, [][]string{{"base", "top"}}, changes)
+	top, _ := store.GetKeymap("top")
+	section := top.Categories["consonants"]
+	assert.Len(t, section.Mappings.All(), 4)
+
+	// A file that fails keeps its previous keymap and is reported, without
+	// holding back the files changed with it
+	before, _ := store.GetCompiled("base")
+	rewrite(t, filepath.Join(directory, "Base.aksj"), `{"id": "base", "categories": {}}`)
+	rewrite(t, filepath.Join(directory, "Other.aksj"), `{"id": "other", "name": "Other", "language": "Hindi", "scheme": "ITRANS", "categories": {"digits": [{"lhs": ["1"], "rhs": ["१"]}]}}`)
+	assert.NoError(t, watcher.Poll())
+	_, found := store.GetKeymap("other")
+	assert.True(t, found, "the valid files of a poll load")
+	after, _ := store.GetCompiled("base")
+	assert.Same(t, before, after)
+	report := store.Report()
+	if assert.Len(t, report.Files, 1) {
+		assert.Equal(t, filepath.Join(directory, "Base.aksj"), report.Files[0].File)
+	}
+	assert.Equal(t, []string{"other"}, changes[1])
+
+	// A failed file is not tried again until it changes
+	assert.NoError(t, watcher.Poll())
+	assert.Len(t, changes, 2)
+	rewrite(t, filepath.Join(directory, "Base.aksj"), baseKeymap)
+	assert.NoError(t, watcher.Poll())
+	assert.True(t, store.Report().OK())
+	assert.Equal(t, []string{"base", "top"}, changes[2])
+
+	// Removing a file removes its keymap, unless another keymap still needs it
+	assert.NoError(t, os.Remove(filepath.Join(directory, "Other.aksj")))
+	assert.NoError(t, watcher.Poll())
+	_, found = store.GetKeymap("other")
+	assert.False(t, found)
+	assert.Equal(t, []string{"other"}, changes[3])
+
+	assert.NoError(t, os.Remove(filepath.Join(directory, "Base.aksj")))
+	assert.NoError(t, watcher.Poll())
+	_, found = store.GetKeymap("base")
+	assert.True(t, found)
+	assert.Len(t, store.Report().Files, 1)
+}
+
+func TestWatchPolls(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{"Base.aksj": baseKeymap})
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	watcher, err := store.Watch(directory, 10*time.Millisecond, func(err error) { t.Error(err) })
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer watcher.Stop()
+
+	rewrite(t, filepath.Join(directory, "Base.aksd"), `{"keymap": "base", "words": [{"lhs": ["kay"], "rhs": ["के"]}]}`)
+	assert.Eventually(t, func() bool {
+		compiled, _ := store.GetCompiled("base")
+		_, _, found := compiled.WordAt([]rune("kay"), 0, 0)
+		return found
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchBrokenDictionary(t *testing.T) {
+	directory := writeKeymaps(t, map[string]string{
+		"Base.aksj": baseKeymap,
+		"Base.aksd": `{"keymap": "base", "words": [{"lhs": ["kay"], "rhs": ["के"]}]}`,
+	})
+	store := NewKeymapStore()
+	if !assert.NoError(t, store.LoadKeymaps(directory)) {
+		return
+	}
+	watcher, err := store.Watch(directory, time.Hour, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer watcher.Stop()
+
+	// A dictionary that fails to load keeps the keymap of the same name from
+	// being loaded, so it is never paired with the previous words, while the
+	// other files edited with them load
+	before, _ := store.GetCompiled("base")
+	rewrite(t, filepath.Join(directory, "Base.aksj"), `{
+		"id": "base", "name": "Renamed", "language": "Hindi", "scheme": "ITRANS",
+		"categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}]}
+	}`)
+	rewrite(t, filepath.Join(directory, "Base.aksd"), `{"keymap": "base", "words": [`)
+	rewrite(t, filepath.Join(directory, "Other.aksj"), `{"id": "other", "name": "Other", "language": "Hindi", "scheme": "ITRANS", "categories": {"digits": [{"lhs": ["1"], "rhs": ["१"]}]}}`)
+	assert.NoError(t, watcher.Poll())
+	after, _ := store.GetCompiled("base")
+	assert.Same(t, before, after)
+	_, found := store.GetKeymap("other")
+	assert.True(t, found)
+	report := store.Report()
+	if assert.Len(t, report.Files, 1) {
+		assert.Equal(t, filepath.Join(directory, "Base.aksd"), report.Files[0].File)
+	}
+
+	rewrite(t, filepath.Join(directory, "Base.aksd"), `{"keymap": "missing", "words": []}`)
+	assert.NoError(t, watcher.Poll())
+	base, _ := store.GetKeymap("base")
+	assert.Equal(t, "Base", base.Name)
+	assert.False(t, store.Report().OK())
+
+	rewrite(t, filepath.Join(directory, "Base.aksd"), `{"keymap": "base", "words": [{"lhs": ["kii"], "rhs": ["की"]}]}`)
+	assert.NoError(t, watcher.Poll())
+	base, _ = store.GetKeymap("base")
+	assert.Equal(t, "Renamed", base.Name)
+	assert.True(t, store.Report().OK())
+	compiled, _ := store.GetCompiled("base")
+	_, _, found = compiled.WordAt([]rune("kay"), 0, 0)
+	assert.False(t, found)
+	_, _, found = compiled.WordAt([]rune("kii"), 0, 0)
+	assert.True(t, found)
+}
@@ -0,0 +1,87 @@
+// Package norm normalizes the text Aksharamala reads and writes. NFC and NFD
+// are the canonical Unicode forms of golang.org/x/text/unicode/norm; Variants
+// adds the form that composes the Indic composition exclusions, such as the
+// Devanagari nukta letters, so that keymaps match text written either way.
+package norm
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization forms a keymap can ask for its output to be written in.
+const (
+	FormNFC = "nfc" // Canonical composition, leaving the composition exclusions decomposed
+	FormNFD = "nfd" // Canonical decomposition
+)
+
+// exclusions are the precomposed Indic letters that NFC never produces, such
+// as the Devanagari nukta letters: they decompose, but do not recompose.
+var exclusions = []rune{
+	'\u0958', '\u0959', '\u095A', '\u095B', '\u095C', '\u095D', '\u095E', '\u095F', // Devanagari
+	'\u09DC', '\u09DD', '\u09DF', // Bengali
+	'\u0A33', '\u0A36', '\u0A59', '\u0A5A', '\u0A5B', '\u0A5E', // Gurmukhi
+	'\u0B5C', '\u0B5D', // Oriya
+}
+
+// precomposer replaces the canonical decomposition of each composition
+// exclusion with the excluded letter.
+var precomposer = func() *strings.Replacer {
+	pairs := make([]string, 0, 2*len(exclusions))
+	for _, r := range exclusions {
+		pairs = append(pairs, norm.NFD.String(string(r)), string(r))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// CombiningClass returns the canonical combining class of r.
+func CombiningClass(r rune) uint8 {
+	return norm.NFD.PropertiesString(string(r)).CCC()
+}
+
+// NFD returns the canonical decomposition of s with combining marks in canonical order.
+func NFD(s string) string {
+	return norm.NFD.String(s)
+}
+
+// NFC returns the canonical composition of s.
+func NFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// Normalize returns s in the given form, FormNFC or FormNFD. Any other form,
+// including "", leaves s as it is.
+func Normalize(form, s string) string {
+	switch form {
+	case FormNFC:
+		return NFC(s)
+	case FormNFD:
+		return NFD(s)
+	}
+	return s
+}
+
+// Variants returns the distinct forms of s under NFC and NFD, starting with s
+// itself, and the form that also composes the composition exclusions, so that
+// text matches whichever of them it is written in.
+func Variants(s string) []string {
+	variants := []string{s}
+	nfc := NFC(s)
+	for _, form := range []string{nfc, NFD(s), precomposer.Replace(nfc)} {
+		if !contains(variants, form) {
+			variants = append(variants, form)
+		}
+	}
+	return variants
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
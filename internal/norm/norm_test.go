@@ -0,0 +1,92 @@
+package norm
+
+import "testing"
+
+// TestNFDAndNFC verifies decomposition, canonical reordering and recomposition.
+func TestNFDAndNFC(t *testing.T) {
+	tests := []struct {
+		composed   string
+		decomposed string
+	}{
+		{"ā", "ā"},
+		{"ṝ", "ṝ"},
+		{"Ḹ", "Ḹ"},
+		{"śāstra", "śāstra"},
+		{"kṛṣṇa", "kṛṣṇa"},
+		{"r̥̄", "r̥̄"}, // No precomposed form exists
+		{"नमस्ते", "नमस्ते"},
+		{"caf\u00E9", "cafe\u0301"}, // Any character, not only those of the keymaps
+		{"\u1E69", "s\u0323\u0307"}, // ṩ, with two marks
+	}
+
+	for _, test := range tests {
+		if got := NFD(test.composed); got != test.decomposed {
+			t.Errorf("NFD(%q) = %q, expected %q", test.composed, got, test.decomposed)
+		}
+		if got := NFC(test.decomposed); got != test.composed {
+			t.Errorf("NFC(%q) = %q, expected %q", test.decomposed, got, test.composed)
+		}
+	}
+
+	// Marks typed out of canonical order are reordered before composing
+	if got := NFC("ṝ"); got != "ṝ" {
+		t.Errorf("Expected reordered marks to compose to 'ṝ', got %q", got)
+	}
+}
+
+// TestVariants verifies that each distinct normalization form is listed once.
+func TestVariants(t *testing.T) {
+	if variants := Variants("k"); len(variants) != 1 {
+		t.Errorf("Expected a single variant for plain ASCII, got %q", variants)
+	}
+
+	variants := Variants("ṭh")
+	if len(variants) != 2 || variants[0] != "ṭh" || variants[1] != "ṭh" {
+		t.Errorf("Unexpected variants for 'ṭh': %q", variants)
+	}
+
+	variants = Variants("ś")
+	if len(variants) != 2 || variants[1] != "ś" {
+		t.Errorf("Expected the composed form as a variant of decomposed input, got %q", variants)
+	}
+}
+
+// TestIndicNormalization verifies the nukta letters, which NFC leaves
+// decomposed, and the two-part vowel signs, which it composes.
+func TestIndicNormalization(t *testing.T) {
+	tests := []struct {
+		input string
+		nfc   string
+		nfd   string
+	}{
+		{"\u0958", "\u0915\u093C", "\u0915\u093C"},                   // क़ is a composition exclusion
+		{"\u0915\u093C", "\u0915\u093C", "\u0915\u093C"},             // and stays decomposed
+		{"\u0928\u093C", "\u0929", "\u0928\u093C"},                   // ऩ composes
+		{"\u0958\u094D", "\u0915\u093C\u094D", "\u0915\u093C\u094D"}, // Nukta before virama
+		{"\u0B95\u0BC6\u0BBE", "\u0B95\u0BCA", "\u0B95\u0BC6\u0BBE"}, // Tamil o sign
+		{"\u0C15\u0C48", "\u0C15\u0C48", "\u0C15\u0C46\u0C56"},       // Telugu ai sign
+		{"\u0CCB", "\u0CCB", "\u0CC6\u0CC2\u0CD5"},                   // Kannada oo sign, decomposed recursively
+	}
+
+	for _, test := range tests {
+		if got := NFC(test.input); got != test.nfc {
+			t.Errorf("NFC(%q) = %q, expected %q", test.input, got, test.nfc)
+		}
+		if got := NFD(test.input); got != test.nfd {
+			t.Errorf("NFD(%q) = %q, expected %q", test.input, got, test.nfd)
+		}
+		if got := Normalize(FormNFC, test.input); got != test.nfc {
+			t.Errorf("Normalize(nfc, %q) = %q, expected %q", test.input, got, test.nfc)
+		}
+	}
+
+	if got := Normalize("", "क़"); got != "क़" {
+		t.Errorf("Expected no normalization without a form, got %q", got)
+	}
+
+	// A decomposed nukta letter also matches its precomposed form
+	variants := Variants("क़")
+	if len(variants) != 2 || variants[1] != "क़" {
+		t.Errorf("Expected the precomposed form as a variant, got %q", variants)
+	}
+}
@@ -1,58 +1,44 @@
 package translit
 
 import (
-	"fmt"
-
 	"aks.go/internal/keymap"
-	"aks.go/internal/types"
 )
 
 // Aksharamala represents a transliteration engine that uses a keymap store
-// to perform transliteration operations. It maintains the active transliteration
-// scheme and manages the context for transliteration processes.
+// to perform transliteration operations. It holds no per-call state and is
+// safe for concurrent use; each operation runs in its own Session.
 type Aksharamala struct {
-	keymapStore   *keymap.KeymapStore
-	activeScheme  *types.TransliterationScheme
-	context       *types.Context
-	viramaHandler *types.ViramaHandler
+	keymapStore *keymap.KeymapStore
 }
 
 // NewAksharamala initializes a new Aksharamala instance.
 func NewAksharamala(store *keymap.KeymapStore) *Aksharamala {
 	return &Aksharamala{
 		keymapStore: store,
-		context:     types.NewContext(),
 	}
 }
 
-// SetActiveKeymap sets the active keymap by ID for transliteration.
-func (a *Aksharamala) SetActiveKeymap(id string) error {
-	scheme, exists := a.keymapStore.GetKeymap(id)
-	if !exists {
-		return fmt.Errorf("keymap with ID '%s' not found", id)
-	}
-
-	virama, viramaMode, err := types.ParseVirama(scheme.Metadata.Virama)
+// TransliterateWithKeymap performs mapping of the input string using the given keymap:
+// the latest version of the keymap with ID id, or the version id names as "id@version".
+func (a *Aksharamala) TransliterateWithKeymap(id, input string) (string, error) {
+	session, err := a.NewSession(id)
 	if err != nil {
-		return fmt.Errorf("failed to parse virama: %v", err)
+		return "", err
 	}
-
-	a.activeScheme = &scheme
-	a.context = types.NewContext()
-	a.viramaHandler = types.NewViramaHandler(viramaMode, virama, a.context)
-	return nil
+	return session.Map(input)
 }
 
-// TransliterateWithKeymap performs mapping of the input string using the active keymap.
-func (a *Aksharamala) TransliterateWithKeymap(id, input string) (string, error) {
-	if err := a.SetActiveKeymap(id); err != nil {
-		return "", err
+// TransliterateVersioned maps input like TransliterateWithKeymap and reports
+// the keymap version that produced the output, so that archived output can
+// be reproduced by passing "id@version" later.
+func (a *Aksharamala) TransliterateVersioned(id, input string) (*Result, error) {
+	session, err := a.NewSession(id)
+	if err != nil {
+		return nil, err
 	}
-	switch a.activeScheme.Scheme {
-	case "Unicode":
-		return a.Reversliterate(input)
-	case "ITRANS", "RTS":
-		return a.Transliterate(input)
+	output, err := session.Map(input)
+	if err != nil {
+		return nil, err
 	}
-	return "", fmt.Errorf("unsupported scheme: %s", a.activeScheme.Scheme)
+	return &Result{Output: output, Keymap: session.KeymapID(), Version: session.Version()}, nil
 }
@@ -0,0 +1,137 @@
+package translit
+
+import (
+	"unicode/utf8"
+
+	"aks.go/internal/types"
+)
+
+// Span is a half-open range of rune offsets.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Segment aligns a span of the input with the span of the output produced from it.
+// Segments are ordered and neither their input nor their output spans overlap;
+// a segment whose output span is empty produced no text, such as a syllable break.
+type Segment struct {
+	Input  Span `json:"input"`
+	Output Span `json:"output"`
+}
+
+// Result is the mapped text together with the keymap version that produced
+// it and, when requested, its alignment to the input.
+type Result struct {
+	Output    string    `json:"output"`
+	Keymap    string    `json:"keymap"`  // ID of the keymap
+	Version   string    `json:"version"` // Version of the keymap, empty if it has none
+	Alignment []Segment `json:"alignment,omitempty"`
+}
+
+// TransliterateAlignedWithKeymap maps input with the given keymap in the keymap's
+// direction and returns the output together with its alignment to the input.
+func (a *Aksharamala) TransliterateAlignedWithKeymap(id, input string) (*Result, error) {
+	session, err := a.NewSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return session.MapAligned(input)
+}
+
+// MapAligned is Map that also returns the alignment of the output to the input.
+func (s *Session) MapAligned(input string) (*Result, error) {
+	return s.aligned(input, s.Map)
+}
+
+// TransliterateAligned is Transliterate that also returns the alignment of the
+// output to the input.
+func (s *Session) TransliterateAligned(input string) (*Result, error) {
+	return s.aligned(input, s.Transliterate)
+}
+
+// ReversliterateAligned is Reversliterate that also returns the alignment of the
+// output to the input.
+func (s *Session) ReversliterateAligned(input string) (*Result, error) {
+	return s.aligned(input, s.Reversliterate)
+}
+
+// aligned runs mapper with alignment recording enabled.
+func (s *Session) aligned(input string, mapper func(string) (string, error)) (*Result, error) {
+	s.aligning = true
+	s.segments = nil
+	defer func() {
+		s.aligning = false
+		s.segments = nil
+	}()
+
+	output, err := mapper(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// A virama written at the end of input completes the last segment
+	if n := len(s.segments); n > 0 {
+		s.segments[n-1].Output.End = len(output)
+	}
+
+	return &Result{Output: output, Keymap: s.scheme.ID, Version: s.scheme.Version, Alignment: toRuneSpans(s.segments, output)}, nil
+}
+
+// alignStep records the segment for a step that consumed input runes [start, next).
+// Output written at the start of the step for the previous token, such as an
+// inserted virama, extends the previous segment. If the step rewrote output
+// of earlier steps, their segments are merged into this one.
+func (s *Session) alignStep(start, next int, result *types.Output) {
+	outStart := s.mark + s.lead
+	if n := len(s.segments); n > 0 && s.lead > 0 {
+		s.segments[n-1].Output.End = outStart
+	}
+
+	current := Segment{Input: Span{Start: start, End: next}, Output: Span{Start: outStart, End: result.Len()}}
+	if s.rewrite < s.mark {
+		for n := len(s.segments); n > 0 && s.segments[n-1].Output.End > s.rewrite; n = len(s.segments) {
+			previous := s.segments[n-1]
+			current.Input.Start = previous.Input.Start
+			current.Output.Start = previous.Output.Start
+			s.segments = s.segments[:n-1]
+		}
+		if current.Output.Start > s.rewrite {
+			current.Output.Start = s.rewrite
+		}
+	}
+	s.segments = append(s.segments, current)
+}
+
+// toRuneSpans converts the byte offsets of the output spans to rune offsets.
+func toRuneSpans(segments []Segment, output string) []Segment {
+	// runeIndex[b] is the index of the rune that byte b belongs to
+	runeIndex := make([]int, len(output)+1)
+	count := 0
+	for b := 0; b < len(output); b++ {
+		runeIndex[b] = count
+		if b+1 == len(output) || utf8.RuneStart(output[b+1]) {
+			count++
+		}
+	}
+	runeIndex[len(output)] = count
+
+	converted := make([]Segment, len(segments))
+	for i, segment := range segments {
+		converted[i] = Segment{
+			Input:  segment.Input,
+			Output: Span{Start: runeIndex[segment.Output.Start], End: runeIndex[segment.Output.End]},
+		}
+	}
+	return converted
+}
+
+// rewrittenFrom returns the offset of the first byte that differs between before
+// and after, or len(before) if after only appends to it.
+func rewrittenFrom(before, after string) int {
+	k := 0
+	for k < len(before) && k < len(after) && before[k] == after[k] {
+		k++
+	}
+	return k
+}
@@ -0,0 +1,114 @@
+package translit
+
+import (
+	"reflect"
+	"testing"
+
+	"aks.go/internal/core"
+	"aks.go/internal/keymap"
+	"aks.go/internal/types"
+)
+
+// TestTransliterateAligned verifies alignments for virama insertion, unmatched
+// characters and reversliteration.
+func TestTransliterateAligned(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id        string
+		input     string
+		output    string
+		alignment []Segment
+	}{
+		{"hindi", "kSh", "क्ष", []Segment{
+			{Span{0, 1}, Span{0, 2}}, // The inserted virama belongs to 'k'
+			{Span{1, 3}, Span{2, 3}},
+		}},
+		{"hindi", "“ka”", "“क”", []Segment{
+			{Span{0, 1}, Span{0, 1}},
+			{Span{1, 2}, Span{1, 2}},
+			{Span{2, 3}, Span{2, 2}}, // The inherent vowel writes nothing
+			{Span{3, 4}, Span{2, 3}},
+		}},
+		{"teluguRts", "kk.", "క్క.", []Segment{
+			{Span{0, 1}, Span{0, 2}},
+			{Span{1, 2}, Span{2, 3}},
+			{Span{2, 3}, Span{3, 4}},
+		}},
+		{"rhindi", "कमल", "kamal", []Segment{
+			{Span{0, 1}, Span{0, 2}},
+			{Span{1, 2}, Span{2, 4}},
+			{Span{2, 3}, Span{4, 5}},
+		}},
+	}
+
+	for _, test := range tests {
+		result, err := aks.TransliterateAlignedWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Error transliterating %q: %v", test.input, err)
+		}
+		if result.Output != test.output {
+			t.Errorf("%s: for input %q, expected %q, got %q", test.id, test.input, test.output, result.Output)
+		}
+		if !reflect.DeepEqual(result.Alignment, test.alignment) {
+			t.Errorf("%s: for input %q, expected alignment %v, got %v", test.id, test.input, test.alignment, result.Alignment)
+		}
+	}
+
+	// Long inputs are covered by contiguous segments in both texts
+	input := "jeevitam aaScharyaala tO niMDinadi. prati avakaasAnni dhairyaMtO sviikariMchaDam manaku avasaram."
+	result, err := aks.TransliterateAlignedWithKeymap("teluguRts", input)
+	if err != nil {
+		t.Fatalf("Error transliterating: %v", err)
+	}
+	var in, out int
+	for _, segment := range result.Alignment {
+		if segment.Input.Start != in || segment.Output.Start != out {
+			t.Fatalf("Segment %v does not continue at input %d, output %d", segment, in, out)
+		}
+		in, out = segment.Input.End, segment.Output.End
+	}
+	if in != len([]rune(input)) || out != len([]rune(result.Output)) {
+		t.Errorf("Segments end at input %d, output %d", in, out)
+	}
+}
+
+// TestAlignedRewrite verifies that a (c) rule rewriting earlier output merges
+// the affected segments.
+func TestAlignedRewrite(t *testing.T) {
+	scheme := types.TransliterationScheme{
+		ID:       "rewrite",
+		Scheme:   types.SchemeITRANS,
+		Metadata: types.Metadata{Virama: "्, smart"},
+		Categories: map[string]types.Section{
+			"others": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"a"}, RHS: []string{"A"}},
+					{LHS: []string{"b"}, RHS: []string{"(c)Z"}},
+				}),
+			},
+		},
+	}
+
+	session, err := newSession(types.CompileScheme(scheme))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	result, err := session.TransliterateAligned("aab")
+	if err != nil {
+		t.Fatalf("Error transliterating: %v", err)
+	}
+	expected := []Segment{
+		{Span{0, 1}, Span{0, 1}},
+		{Span{1, 3}, Span{1, 2}},
+	}
+	if result.Output != "AZ" || !reflect.DeepEqual(result.Alignment, expected) {
+		t.Errorf("Expected 'AZ' aligned as %v, got %q aligned as %v", expected, result.Output, result.Alignment)
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"aks.go/internal/types"
 )
 
-// BuildLookupTable constructs a precomputed lookup table from a transliteration scheme
+// BuildLookupTable constructs a precomputed lookup table from a transliteration scheme.
+// When an LHS is mapped more than once, the first mapping in precedence order wins.
 func BuildLookupTable(scheme *types.TransliterationScheme) core.LookupTable {
 	table := make(core.LookupTable)
-	for category, section := range scheme.Categories {
+	for _, category := range scheme.CategoryNames() {
+		section := scheme.Categories[category]
 		for _, mapping := range section.Mappings.All() {
 			for _, lhs := range mapping.LHS {
+				if _, exists := table[lhs]; exists {
+					continue
+				}
 				result := core.LookupResult{
 					Category: category,
 					Found:    true,
@@ -53,3 +53,22 @@ func TestBuildLookupTable(t *testing.T) {
 		t.Errorf("For input 'z': expected empty output and 'other' category, got %+v", result)
 	}
 }
+
+// TestBuildLookupTablePrecedence verifies that the first category in file order
+// wins when two categories map the same LHS.
+func TestBuildLookupTablePrecedence(t *testing.T) {
+	scheme := &types.TransliterationScheme{
+		Categories: map[string]types.Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{{LHS: []string{"k"}, RHS: []string{"क"}}})},
+			"others":     {Mappings: core.NewMappings([]core.Mapping{{LHS: []string{"k"}, RHS: []string{"ॐ"}}})},
+		},
+		Order: []string{"others", "consonants"},
+	}
+
+	for i := 0; i < 10; i++ {
+		result := BuildLookupTable(scheme).Lookup("k")
+		if result.Output != "ॐ" || result.Category != "others" {
+			t.Fatalf("Expected the mapping of 'others', got %+v", result)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package translit
+
+import (
+	"fmt"
+	"unicode"
+
+	"aks.go/internal/core"
+	"aks.go/internal/types"
+)
+
+// ComposerState is what an input method shows after a keystroke.
+type ComposerState struct {
+	Committed string `json:"committed"` // Final text to append to the document
+	Preedit   string `json:"preedit"`   // Tentative text for the keystrokes still pending
+}
+
+// composerSnapshot is the stable state of the pending word after a keystroke.
+type composerSnapshot struct {
+	position       int          // Keystrokes consumed by steps that later keys cannot change
+	output         []types.Unit // Output written by those steps
+	latestLookup   core.LookupResult
+	currentContext string
+	passThrough    bool
+}
+
+// Composer transliterates input one keystroke at a time for input methods.
+// Keystrokes of the current word stay pending and are shown as preedit text;
+// typing whitespace commits the word. Only keystrokes close enough to the end
+// of the word to still change the longest match are re-processed per key, but
+// each keystroke copies the output of the pending word, so its cost grows
+// with the length of the word, though not with the text committed before it.
+// Like a Session, a Composer must not be shared between goroutines.
+type Composer struct {
+	session   *Session
+	keys      []rune             // Keystrokes of the pending word
+	snapshots []composerSnapshot // snapshots[n] is the stable state after n keystrokes
+}
+
+// NewComposer creates a Composer for the forward keymap with the given ID.
+func (a *Aksharamala) NewComposer(id string) (*Composer, error) {
+	session, err := a.NewSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.scheme.Scheme == types.SchemeUnicode {
+		return nil, fmt.Errorf("keymap '%s' is a reversliteration scheme and cannot compose keystrokes", id)
+	}
+
+	c := &Composer{session: session}
+	c.Reset()
+	return c, nil
+}
+
+// Type adds a keystroke. Whitespace ends the pending word and commits it
+// together with the whitespace; any other key extends the preedit.
+func (c *Composer) Type(key rune) ComposerState {
+	c.keys = append(c.keys, key)
+	if unicode.IsSpace(key) {
+		committed := c.run(false)
+		c.startWord()
+		return ComposerState{Committed: committed}
+	}
+
+	// Advance over the keystrokes no future key can re-match
+	last := c.snapshots[len(c.snapshots)-1]
+	result := c.restore(last)
+	i := last.position
+	for limit := c.session.holdWord(c.keys, len(c.keys)-c.session.compiled.MaxLHSLength()); i < limit; {
+		i = c.session.transliterateStep(c.keys, i, result)
+	}
+	c.snapshots = append(c.snapshots, c.capture(i, result))
+
+	return ComposerState{Preedit: c.run(true)}
+}
+
+// Backspace removes the last pending keystroke, not the last output rune.
+// It returns false when nothing is pending, in which case the input method
+// should let the application handle the key.
+func (c *Composer) Backspace() (ComposerState, bool) {
+	if len(c.keys) == 0 {
+		return ComposerState{}, false
+	}
+
+	c.keys = c.keys[:len(c.keys)-1]
+	c.snapshots = c.snapshots[:len(c.keys)+1]
+	if len(c.keys) == 0 {
+		return ComposerState{}, true
+	}
+	return ComposerState{Preedit: c.run(true)}, true
+}
+
+// Commit finalizes the pending keystrokes as if the input ended here and
+// starts over with a clean state.
+func (c *Composer) Commit() ComposerState {
+	committed := c.run(true)
+	c.Reset()
+	return ComposerState{Committed: committed}
+}
+
+// Reset discards any pending keystrokes and all carried context.
+func (c *Composer) Reset() {
+	c.session.reset("")
+	c.startWord()
+}
+
+// Preedit returns the tentative output for the pending keystrokes.
+func (c *Composer) Preedit() string {
+	if len(c.keys) == 0 {
+		return ""
+	}
+	return c.run(true)
+}
+
+// startWord clears the pending word, keeping the context of the committed text.
+func (c *Composer) startWord() {
+	c.keys = nil
+	c.snapshots = append(c.snapshots[:0], c.capture(0, &types.Output{}))
+}
+
+// run transliterates the pending keystrokes from the latest snapshot to the end
+// and returns the output for the whole word. When final is true, a virama still
+// pending at the end is resolved as at the end of input.
+func (c *Composer) run(final bool) string {
+	last := c.snapshots[len(c.snapshots)-1]
+	result := c.restore(last)
+	for i := last.position; i < len(c.keys); {
+		i = c.session.transliterateStep(c.keys, i, result)
+	}
+	if final {
+		c.session.finishTransliterate(result)
+	}
+	return result.String()
+}
+
+// restore loads a snapshot into the session and returns the output it holds.
+func (c *Composer) restore(snapshot composerSnapshot) *types.Output {
+	c.session.context.SetInput(string(c.keys))
+	c.session.context.LatestLookup = snapshot.latestLookup
+	c.session.context.CurrentContext = snapshot.currentContext
+	c.session.context.PassThrough = snapshot.passThrough
+
+	result := &types.Output{}
+	for _, unit := range snapshot.output {
+		result.Write(unit.Text, unit.Category)
+	}
+	return result
+}
+
+// capture records the session state after the stable steps up to position.
+func (c *Composer) capture(position int, result *types.Output) composerSnapshot {
+	return composerSnapshot{
+		position:       position,
+		output:         result.Units(),
+		latestLookup:   c.session.context.LatestLookup,
+		currentContext: c.session.context.CurrentContext,
+		passThrough:    c.session.context.PassThrough,
+	}
+}
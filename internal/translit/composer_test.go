@@ -0,0 +1,124 @@
+package translit
+
+import (
+	"testing"
+
+	"aks.go/internal/keymap"
+)
+
+// typeAll feeds input to the composer one key at a time and returns the
+// committed text followed by the final commit.
+func typeAll(c *Composer, input string) string {
+	var committed string
+	for _, key := range input {
+		committed += c.Type(key).Committed
+	}
+	return committed + c.Commit().Committed
+}
+
+// TestComposerMatchesTransliterate verifies that composing keystroke by keystroke
+// commits the same text as transliterating the whole input at once.
+func TestComposerMatchesTransliterate(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	if err := store.LoadDictionary("../../keymaps/dictionaries/Hindi.aksd"); err != nil {
+		t.Fatalf("Failed to load dictionary: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		keymap string
+		input  string
+	}{
+		{"hindi", "yah ek su.ndar din hai. aaj ham bahut khush hai.n."},
+		{"hindi", "kShatriya GYaan .Dh a.c"},
+		{"hindi", "Google par Delhi, Mumbaii computer."},
+		{"teluguRts", "jeevitam aaScharyaala tO niMDinadi. manaku avasaram."},
+		{"teluguRts", "kk kkk daas"},
+		{"teluguRts", "nEnu \\#Go code\\# raastaanu"},
+		{"iast", "rāmaḥ vanaṃ gacchati |\nsaṃskṛtam"},
+	}
+
+	for _, test := range tests {
+		session, err := aks.NewSession(test.keymap)
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		expected, _ := session.Transliterate(test.input)
+
+		composer, err := aks.NewComposer(test.keymap)
+		if err != nil {
+			t.Fatalf("Failed to create composer: %v", err)
+		}
+		if output := typeAll(composer, test.input); output != expected {
+			t.Errorf("%s: for input %q, expected %q but got %q", test.keymap, test.input, expected, output)
+		}
+	}
+}
+
+// TestComposerPreeditAndBackspace verifies preedit updates, keystroke-level
+// backspace and commits at word boundaries.
+func TestComposerPreeditAndBackspace(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+	composer, err := aks.NewComposer("hindi")
+	if err != nil {
+		t.Fatalf("Failed to create composer: %v", err)
+	}
+
+	steps := []struct {
+		key       rune // 0 means backspace
+		committed string
+		preedit   string
+	}{
+		{'k', "", "क"},
+		{'h', "", "ख"},
+		{0, "", "क"}, // Undo the 'h' keystroke, not the 'ख' rune
+		{'a', "", "क"},
+		{'a', "", "का"},
+		{'m', "", "काम"},
+		{' ', "काम ", ""},
+		{'s', "", "स"},
+		{0, "", ""},
+	}
+
+	for i, step := range steps {
+		var state ComposerState
+		if step.key == 0 {
+			state, _ = composer.Backspace()
+		} else {
+			state = composer.Type(step.key)
+		}
+		if state.Committed != step.committed || state.Preedit != step.preedit {
+			t.Errorf("Step %d (%q): expected committed %q, preedit %q; got %q, %q",
+				i, step.key, step.committed, step.preedit, state.Committed, state.Preedit)
+		}
+	}
+
+	if _, handled := composer.Backspace(); handled {
+		t.Errorf("Expected backspace with nothing pending to be left to the application")
+	}
+
+	// Normal virama mode shows the pending virama in the preedit
+	composer, err = aks.NewComposer("teluguRts")
+	if err != nil {
+		t.Fatalf("Failed to create composer: %v", err)
+	}
+	if state := composer.Type('k'); state.Preedit != "క్" {
+		t.Errorf("Expected preedit 'క్', got %q", state.Preedit)
+	}
+	if state := composer.Type('a'); state.Preedit != "క" {
+		t.Errorf("Expected preedit 'క', got %q", state.Preedit)
+	}
+
+	if _, err := aks.NewComposer("rhindi"); err == nil {
+		t.Errorf("Expected an error composing with a reversliteration keymap")
+	}
+}
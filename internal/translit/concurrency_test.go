@@ -0,0 +1,73 @@
+package translit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"aks.go/internal/keymap"
+)
+
+// TestConcurrentTransliterateWithKeymap runs many goroutines that share one
+// Aksharamala while mixing keymaps, and checks every result against the output
+// of a sequential run. Run with -race to verify the engine shares no mutable state.
+func TestConcurrentTransliterateWithKeymap(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	cases := []struct {
+		id    string
+		input string
+	}{
+		{"hindi", "yah ek su.ndar din hai."},
+		{"marathi", "namaste"},
+		{"teluguRts", "jeevitam aaScharyaala tO niMDinadi."},
+		{"rhindi", "नमस्ते"},
+		{"rsanskrit", "संस्कृत"},
+	}
+
+	// Compute the expected output sequentially
+	expected := make([]string, len(cases))
+	for i, c := range cases {
+		output, err := aks.TransliterateWithKeymap(c.id, c.input)
+		if err != nil {
+			t.Fatalf("Sequential run failed for %s: %v", c.id, err)
+		}
+		expected[i] = output
+	}
+
+	const goroutines = 32
+	const iterations = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < iterations; n++ {
+				i := (g + n) % len(cases)
+				output, err := aks.TransliterateWithKeymap(cases[i].id, cases[i].input)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if output != expected[i] {
+					errs <- fmt.Errorf("keymap %s, input %q: expected %q, got %q",
+						cases[i].id, cases[i].input, expected[i], output)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,282 @@
+package translit
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"aks.go/internal/types"
+)
+
+// Pipeline chains keymaps so that the output of each stage is the input of the
+// next, for example Devanagari→(rsanskrit)→ITRANS→(teluguRts)→Telugu. A Pipeline
+// holds only compiled keymaps and is safe for concurrent use.
+type Pipeline struct {
+	stages []*types.CompiledScheme
+	gaps   []PivotGap
+	schwa  *bool // Schwa deletion of the final stage, when set to override its keymap
+}
+
+// PivotGap is an output of one stage that the next stage cannot fully read.
+type PivotGap struct {
+	From   string `json:"from"`   // ID of the keymap producing the output
+	To     string `json:"to"`     // ID of the keymap reading it
+	Output string `json:"output"` // The mapping output
+	Unread string `json:"unread"` // The parts of the output no mapping of To matches
+}
+
+// LostText is input of a pipeline stage that no mapping of the stage matched,
+// so it reaches the next stage or the final output unconverted.
+type LostText struct {
+	Stage  string `json:"stage"`  // ID of the keymap that could not convert the text
+	Offset int    `json:"offset"` // Offset in runes into the input of that stage
+	Text   string `json:"text"`
+}
+
+// PipelineResult is the output of a pipeline run.
+type PipelineResult struct {
+	Output  string     `json:"output"`
+	Keymaps []string   `json:"keymaps"` // Versioned IDs of the stages, "id@version"
+	Pivots  []string   `json:"pivots"`  // Intermediate texts, one per stage but the last
+	Lost    []LostText `json:"lost,omitempty"`
+}
+
+// NewPipeline builds a pipeline from keymap IDs. Each keymap must read what
+// the keymap before it writes: a Unicode keymap writes the romanization named
+// by its "romanization" metadata, which the next keymap must take as input,
+// and a romanized-input keymap writes the script of its language, which the
+// next keymap must read. Outputs of a stage that the next stage cannot read
+// are reported by Gaps rather than rejected, since a pivot rarely lines up
+// perfectly.
+func (a *Aksharamala) NewPipeline(ids ...string) (*Pipeline, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("a pipeline needs at least one keymap")
+	}
+
+	pipeline := &Pipeline{}
+	for i, id := range ids {
+		compiled, exists := a.keymapStore.GetCompiled(id)
+		if !exists {
+			return nil, fmt.Errorf("keymap with ID '%s' not found", id)
+		}
+		if i > 0 {
+			previous := pipeline.stages[i-1]
+			if err := checkPivot(previous, compiled); err != nil {
+				return nil, err
+			}
+			pipeline.gaps = append(pipeline.gaps, pivotGaps(previous, compiled)...)
+		}
+		pipeline.stages = append(pipeline.stages, compiled)
+	}
+	return pipeline, nil
+}
+
+// TransliteratePipeline runs input through the keymaps with the given IDs in order.
+func (a *Aksharamala) TransliteratePipeline(ids []string, input string) (*PipelineResult, error) {
+	pipeline, err := a.NewPipeline(ids...)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Run(input)
+}
+
+// Gaps returns the outputs of each stage that the following stage cannot read.
+func (p *Pipeline) Gaps() []PivotGap {
+	return p.gaps
+}
+
+// SetSchwaDeletion turns schwa deletion on or off for the final stage, which
+// writes the output, overriding its keymap's "schwa" metadata like
+// Session.SetSchwaDeletion. Call it before the pipeline is shared.
+func (p *Pipeline) SetSchwaDeletion(enabled bool) {
+	p.schwa = &enabled
+}
+
+// Run maps input through every stage and reports the letters and marks that
+// a stage could not convert. Unmatched whitespace, digits and punctuation are
+// expected to pass through and are not reported.
+func (p *Pipeline) Run(input string) (*PipelineResult, error) {
+	result := &PipelineResult{}
+	text := input
+	for i, compiled := range p.stages {
+		session, err := newSession(compiled)
+		if err != nil {
+			return nil, err
+		}
+		if p.schwa != nil && i == len(p.stages)-1 {
+			session.SetSchwaDeletion(*p.schwa)
+		}
+		output, unmatched, err := session.mapUnmatched(text)
+		if err != nil {
+			return nil, err
+		}
+		result.Lost = append(result.Lost, lostText(compiled.Scheme.ID, []rune(text), unmatched)...)
+		result.Keymaps = append(result.Keymaps, compiled.Scheme.VersionedID())
+
+		text = output
+		if i < len(p.stages)-1 {
+			result.Pivots = append(result.Pivots, text)
+		}
+	}
+	result.Output = text
+	return result, nil
+}
+
+// isReverse reports whether a keymap maps native script to a romanization.
+func isReverse(compiled *types.CompiledScheme) bool {
+	return compiled.Scheme.Scheme == types.SchemeUnicode
+}
+
+// inputKind describes the input a keymap takes, for error messages.
+func inputKind(compiled *types.CompiledScheme) string {
+	if isReverse(compiled) {
+		return "native script"
+	}
+	return "romanized"
+}
+
+// scripts gives the script of the languages keymaps are written for. A
+// language missing here is taken to name its script, as in "Devanagari".
+var scripts = map[string]string{
+	"hindi":    "Devanagari",
+	"marathi":  "Devanagari",
+	"nepali":   "Devanagari",
+	"sanskrit": "Devanagari",
+}
+
+// scriptOf returns the script a keymap for language reads or writes.
+func scriptOf(language string) string {
+	if script, exists := scripts[strings.ToLower(language)]; exists {
+		return script
+	}
+	return language
+}
+
+// alsoReads lists, by romanization, the other romanizations its keymaps read.
+// RTS keeps the ITRANS spelling of most letters; the letters it spells
+// differently are reported as pivot gaps.
+var alsoReads = map[string][]string{
+	"RTS": {"ITRANS"},
+}
+
+// checkPivot returns an error unless to reads what from writes: a
+// romanization to takes as input, or text in the script to reads. A Unicode
+// keymap that does not name its romanization can feed any romanized-input keymap.
+func checkPivot(from, to *types.CompiledScheme) error {
+	if isReverse(from) == isReverse(to) {
+		return fmt.Errorf("keymap '%s' cannot read the output of '%s': both take %s input",
+			to.Scheme.ID, from.Scheme.ID, inputKind(to))
+	}
+
+	if isReverse(from) {
+		written, read := from.Scheme.Metadata.Romanization, to.Scheme.Scheme
+		if written == "" || strings.EqualFold(written, read) || containsFold(alsoReads[strings.ToUpper(read)], written) {
+			return nil
+		}
+		return fmt.Errorf("keymap '%s' cannot read the output of '%s': it takes %s input, not %s",
+			to.Scheme.ID, from.Scheme.ID, read, written)
+	}
+
+	written, read := scriptOf(from.Scheme.Language), scriptOf(to.Scheme.Language)
+	if strings.EqualFold(written, read) {
+		return nil
+	}
+	return fmt.Errorf("keymap '%s' cannot read the output of '%s': it takes %s input, not %s",
+		to.Scheme.ID, from.Scheme.ID, read, written)
+}
+
+// containsFold reports whether list holds s in any letter case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// pivotGaps lists the outputs of from that to cannot read completely.
+func pivotGaps(from, to *types.CompiledScheme) []PivotGap {
+	var gaps []PivotGap
+	seen := make(map[string]bool)
+	for _, category := range from.Scheme.CategoryNames() {
+		section := from.Scheme.Categories[category]
+		for _, mapping := range section.Mappings.All() {
+			if len(mapping.RHS) == 0 {
+				continue
+			}
+			output, _ := types.ParseContextualRules(mapping.RHS[0])
+			if seen[output] || types.IsControlOutput(output) {
+				continue
+			}
+			seen[output] = true
+
+			if unread := unreadParts(to, output); unread != "" {
+				gaps = append(gaps, PivotGap{From: from.Scheme.ID, To: to.Scheme.ID, Output: output, Unread: unread})
+			}
+		}
+	}
+	return gaps
+}
+
+// unreadParts splits text into longest matches of compiled and returns the
+// letters and marks no mapping matches, separated by spaces.
+func unreadParts(compiled *types.CompiledScheme, text string) string {
+	var unread []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if matches := compiled.MatchRunes(runes, i); len(matches) > 0 {
+			i += matches[0].Length
+			continue
+		}
+		if isConvertible(runes[i]) {
+			unread = append(unread, string(runes[i]))
+		}
+		i++
+	}
+	return strings.Join(unread, " ")
+}
+
+// mapUnmatched maps input like Map and also returns the rune offsets of the
+// input no mapping matched, without the cost of a full trace.
+func (s *Session) mapUnmatched(input string) (string, []int, error) {
+	s.collecting = true
+	s.unmatched = nil
+	defer func() {
+		s.collecting = false
+		s.unmatched = nil
+	}()
+
+	output, err := s.Map(input)
+	return output, s.unmatched, err
+}
+
+// recordUnmatched records that no mapping matched the input rune at offset i,
+// when the session is collecting unmatched input.
+func (s *Session) recordUnmatched(i int) {
+	if s.collecting {
+		s.unmatched = append(s.unmatched, i)
+	}
+}
+
+// lostText collects the unmatched letters and marks of a stage's input at the
+// offsets of unmatched, joining adjacent ones.
+func lostText(stage string, input []rune, unmatched []int) []LostText {
+	var lost []LostText
+	for _, offset := range unmatched {
+		if !isConvertible(input[offset]) {
+			continue
+		}
+		if n := len(lost); n > 0 && lost[n-1].Offset+len([]rune(lost[n-1].Text)) == offset {
+			lost[n-1].Text += string(input[offset])
+			continue
+		}
+		lost = append(lost, LostText{Stage: stage, Offset: offset, Text: string(input[offset])})
+	}
+	return lost
+}
+
+// isConvertible reports whether r is text a keymap is expected to convert.
+func isConvertible(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsSymbol(r)
+}
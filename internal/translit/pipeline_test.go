@@ -0,0 +1,114 @@
+package translit
+
+import (
+	"testing"
+
+	"aks.go/internal/keymap"
+)
+
+// TestPipeline verifies script-to-script mapping through a romanized pivot and
+// the reports of pivot gaps and lost text.
+func TestPipeline(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		name   string
+		ids    []string
+		input  string
+		output string
+		pivots []string
+	}{
+		{"Devanagari to Telugu through ITRANS", []string{"rsanskrit", "teluguRts"}, "धर्म", "ధర్మ", []string{"dharma"}},
+		{"Devanagari to Devanagari through IAST", []string{"riast", "iast"}, "क्षेत्र", "क्षेत्र", []string{"kṣetra"}},
+		{"ITRANS to IAST through Devanagari", []string{"hindi", "riast"}, "rAma", "rāma", []string{"राम"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := aks.TransliteratePipeline(tt.ids, tt.input)
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+			if result.Output != tt.output || len(result.Pivots) != len(tt.pivots) || result.Pivots[0] != tt.pivots[0] {
+				t.Errorf("Expected %q through %q, got %+v", tt.output, tt.pivots, result)
+			}
+			if len(result.Lost) != 0 {
+				t.Errorf("Expected nothing lost, got %+v", result.Lost)
+			}
+		})
+	}
+
+	// Adjacent keymaps must run in opposite directions
+	if _, err := aks.NewPipeline("hindi", "teluguRts"); err == nil {
+		t.Error("Expected an error for two romanized-input keymaps in a row")
+	}
+	// Each keymap must read the romanization or script the one before it writes
+	if _, err := aks.NewPipeline("riast", "teluguRts"); err == nil {
+		t.Error("Expected an error for IAST output read as RTS")
+	}
+	if _, err := aks.NewPipeline("teluguRts", "rsanskrit"); err == nil {
+		t.Error("Expected an error for Telugu output read as Devanagari")
+	}
+	if _, err := aks.NewPipeline(); err == nil {
+		t.Error("Expected an error for an empty pipeline")
+	}
+	if _, err := aks.NewPipeline("rsanskrit", "missing"); err == nil {
+		t.Error("Expected an error for an unknown keymap")
+	}
+
+	// ITRANS output that RTS does not read is reported as a gap
+	pipeline, err := aks.NewPipeline("rsanskrit", "teluguRts")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	found := false
+	for _, gap := range pipeline.Gaps() {
+		if gap.From != "rsanskrit" || gap.To != "teluguRts" {
+			t.Errorf("Unexpected gap stages: %+v", gap)
+		}
+		if gap.Output == "H" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected visarga 'H' to be reported as a gap, got %+v", pipeline.Gaps())
+	}
+
+	// Letters a stage cannot map are reported once per stage they pass through
+	result, err := pipeline.Run("नमः ऴ")
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	want := []LostText{
+		{Stage: "rsanskrit", Offset: 4, Text: "ऴ"},
+		{Stage: "teluguRts", Offset: 4, Text: "H"},
+		{Stage: "teluguRts", Offset: 6, Text: "ऴ"},
+	}
+	if len(result.Lost) != len(want) {
+		t.Fatalf("Expected lost text %+v, got %+v", want, result.Lost)
+	}
+	for i := range want {
+		if result.Lost[i] != want[i] {
+			t.Errorf("Lost[%d]: expected %+v, got %+v", i, want[i], result.Lost[i])
+		}
+	}
+
+	// Schwa deletion applies to the final stage, which writes the output
+	pipeline, err = aks.NewPipeline("hindi", "rhindi")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	pipeline.SetSchwaDeletion(true)
+	result, err = pipeline.Run("kamaraa")
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if result.Output != "kamraa" || result.Pivots[0] != "कमरा" {
+		t.Errorf("Expected %q through %q, got %+v", "kamraa", "कमरा", result)
+	}
+}
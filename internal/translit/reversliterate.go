@@ -2,7 +2,6 @@ package translit
 
 import (
 	"fmt"
-	"strings"
 
 	"aks.go/internal/core"
 	"aks.go/internal/types"
@@ -10,85 +9,124 @@ import (
 
 // ReversliterateWithKeymap performs reversliteration of text (generally from Unicode to ITRANS).
 func (a *Aksharamala) ReversliterateWithKeymap(id, input string) (string, error) {
-	if err := a.SetActiveKeymap(id); err != nil {
+	session, err := a.NewSession(id)
+	if err != nil {
 		return "", err
 	}
 
 	// Check if this is a Unicode scheme
-	if a.activeScheme.Scheme != "Unicode" {
+	if session.scheme.Scheme != types.SchemeUnicode {
 		return "", fmt.Errorf("reversliteration is only supported for Unicode schemes")
 	}
 
-	return a.Reversliterate(input)
+	return session.Reversliterate(input)
 }
 
-func (a *Aksharamala) Reversliterate(input string) (string, error) {
+// Reversliterate performs reversliteration for the input string using the session's keymap.
+// Returns the reversliterated string.
+func (s *Session) Reversliterate(input string) (string, error) {
 	// Reset context for a clean state
-	a.context = types.NewContext()
-	a.context.Input = input
+	s.reset(input)
 
-	virama, viramaMode, err := types.ParseVirama(a.activeScheme.Metadata.Virama)
-	if err != nil {
-		return "", err
+	var result types.Output
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		i = s.reversliterateStep(runes, i, &result)
 	}
 
-	var result strings.Builder
-	runes := []rune(input)
+	return result.String(), nil
+}
+
+// reversliterateStep processes the token starting at rune offset i of runes,
+// writes its output to result and returns the offset of the next token.
+func (s *Session) reversliterateStep(runes []rune, i int, result *types.Output) int {
+	s.context.Position = i
+	s.mark = result.Len()
+	s.lead = 0
+	s.rewrite = s.mark
+
+	step := s.beginStep(i)
+	next := s.reversliterateToken(runes, i, result, step)
+	if step != nil {
+		s.endStep(step, runes, next, result.Since(s.mark))
+	}
+	if s.aligning {
+		s.alignStep(i, next, result)
+	}
+	return next
+}
+
+// reversliterateToken does the work of reversliterateStep, recording its
+// decisions in step when tracing.
+func (s *Session) reversliterateToken(runes []rune, i int, result *types.Output, step *TraceStep) int {
+	virama, viramaMode := s.viramaHandler.Virama, s.viramaHandler.Mode
 	length := len(runes)
 
-	for i := 0; i < length; {
-		a.context.Position = i
-		foundMatch := false
-
-		// Greedily match substrings to find the longest match
-		for j := length - i; j > 0; j-- {
-			if i+j <= length {
-				substr := string(runes[i : i+j])
-				lookup := a.lookup(substr)
-
-				if lookup.Found {
-					a.context.LatestLookup = lookup
-
-					// Handle based on category
-					switch lookup.Category {
-					case "consonants":
-						result.WriteString(lookup.Output)
-						// Add virama if we're at the end OR if next char isn't a matra
-						if i+j >= length ||
-							(i+j < length && a.lookup(string(runes[i+j])).Category != "matras") {
-							if viramaMode == types.NormalMode {
-								result.WriteString(virama)
-							} else if viramaMode == types.SmartMode && !a.context.IsSeparator() {
-								result.WriteString(virama)
-							}
-						}
-					case "matras":
-						if lookup.Output != "\u0000" { // Ignore empty matra
-							result.WriteString(lookup.Output)
-						}
-					case "vowels", "others", "digits":
-						result.WriteString(lookup.Output)
-					}
+	// Whole words of the dictionary take precedence over the mappings
+	if output, n, ok := s.compiled.WordAt(runes, i, s.context.Previous); ok {
+		step.word(string(runes[i:i+n]), output)
+		result.Write(output, core.CategoryOther)
+		s.context.LatestLookup = core.LookupResult{Output: output, Category: core.CategoryOther, Found: true, MatchLength: n}
+		return i + n
+	}
 
-					i += j // Move the index forward by the length of the match
-					foundMatch = true
-					break
-				}
-			}
+	// Walk the compiled trie; candidates come back longest first
+	for _, match := range s.compiled.MatchRunes(runes, i) {
+		lookup := s.resolve(match.Entries, match.Length)
+		if !lookup.Found {
+			continue
 		}
 
-		// If no match was found, copy the character as is
-		if !foundMatch {
-			result.WriteString(string(runes[i]))
-			a.context.LatestLookup = core.LookupResult{
-				Output:      string(runes[i]),
-				Category:    "other",
-				Found:       false,
-				MatchLength: 1,
+		s.context.LatestLookup = lookup
+		next := i + match.Length
+		step.match(string(runes[i:next]), lookup.Output, lookup.Category, lookup.Index, lookup.Alternative)
+
+		// Handle based on the role of the category
+		switch s.compiled.Role(lookup.Category) {
+		case types.RoleConsonant:
+			result.Write(lookup.Output, lookup.Category)
+			// Add virama if we're at the end OR if next char isn't a vowel sign
+			if next >= length || s.compiled.Role(s.lookup(string(runes[next])).Category) != types.RoleDependentVowel {
+				if s.schwaDeletion {
+					if deleted, reason := s.schwaDeleted(runes, i); deleted {
+						step.virama(false, reason)
+					} else {
+						result.Write(virama, "virama")
+						step.virama(true, reason)
+					}
+				} else if viramaMode == types.NormalMode {
+					result.Write(virama, "virama")
+					step.virama(true, "no vowel sign follows the consonant")
+				} else if viramaMode == types.SmartMode && !s.context.IsSeparator() {
+					result.Write(virama, "virama")
+					step.virama(true, "no vowel sign follows the consonant within a word")
+				} else {
+					step.virama(false, "inherent vowel is dropped at the end of a word in smart mode")
+				}
+			} else {
+				step.virama(false, "a vowel sign follows the consonant")
+			}
+		default:
+			if lookup.Output != core.SyllableBreak { // A bare virama writes nothing
+				result.Write(lookup.Output, lookup.Category)
 			}
-			i++
 		}
+
+		return next // Move the index forward by the length of the match
 	}
 
-	return result.String(), nil
+	// If no match was found, copy the character as is. Joiners the keymap
+	// does not map have no meaning in the romanized output and are dropped.
+	if char := string(runes[i]); char != core.ZWNJ && char != core.ZWJ {
+		result.Write(s.compiled.Normalize(char), core.CategoryOther)
+	}
+	step.action(ActionUnmatched)
+	s.recordUnmatched(i)
+	s.context.LatestLookup = core.LookupResult{
+		Output:      string(runes[i]),
+		Category:    core.CategoryOther,
+		Found:       false,
+		MatchLength: 1,
+	}
+	return i + 1
 }
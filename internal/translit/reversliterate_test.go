@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"testing"
 
+	"aks.go/internal/core"
 	"aks.go/internal/keymap"
+	"aks.go/internal/types"
 )
 
 func TestReversliterate(t *testing.T) {
@@ -45,13 +47,14 @@ func TestReversliterate(t *testing.T) {
 	}
 
 	// Test RHindi (smart mode)
-	if err := aks.SetActiveKeymap("rhindi"); err != nil {
-		t.Fatalf("Failed to set active keymap: %v", err)
+	session, err := aks.NewSession("rhindi")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
 	}
 
 	for _, test := range smartTests {
 		t.Run(fmt.Sprintf("Smart_%s(%s)", test.input, test.desc), func(t *testing.T) {
-			output, err := aks.Reversliterate(test.input)
+			output, err := session.Reversliterate(test.input)
 			if err != nil {
 				t.Errorf("Error reversliterating '%s': %v", test.input, err)
 				return
@@ -109,13 +112,14 @@ func TestReversliterate(t *testing.T) {
 	}
 
 	// Test RSanskrit (normal mode)
-	if err := aks.SetActiveKeymap("rsanskrit"); err != nil {
-		t.Fatalf("Failed to set active keymap: %v", err)
+	session, err = aks.NewSession("rsanskrit")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
 	}
 
 	for _, test := range normalTests {
 		t.Run(fmt.Sprintf("Normal_%s(%s)", test.input, test.desc), func(t *testing.T) {
-			output, err := aks.Reversliterate(test.input)
+			output, err := session.Reversliterate(test.input)
 			if err != nil {
 				t.Errorf("Error reversliterating '%s': %v", test.input, err)
 				return
@@ -130,3 +134,106 @@ func TestReversliterate(t *testing.T) {
 		})
 	}
 }
+
+// TestCategoryRoles verifies that the engine treats categories by their
+// declared roles rather than their names, in both directions.
+func TestCategoryRoles(t *testing.T) {
+	forward := types.TransliterationScheme{
+		ID:     "roles",
+		Scheme: types.SchemeITRANS,
+		Metadata: types.Metadata{
+			Virama: "्, smart",
+			Roles:  map[string]types.Role{"vyanjan": types.RoleConsonant, "svar": types.RoleIndependentVowel, "accents": types.RoleOther},
+		},
+		Categories: map[string]types.Section{
+			"vyanjan": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"m"}, RHS: []string{"म"}},
+			})},
+			"svar": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"a"}, RHS: []string{"अ", "\u0000"}},
+				{LHS: []string{"i"}, RHS: []string{"इ", "ि"}},
+			})},
+			"accents": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"\\_"}, RHS: []string{"॒"}},
+			})},
+		},
+	}
+	reverse := types.TransliterationScheme{
+		ID:     "rroles",
+		Scheme: types.SchemeUnicode,
+		Metadata: types.Metadata{
+			Virama: "a, smart",
+			Roles:  map[string]types.Role{"vyanjan": types.RoleConsonant, "signs": types.RoleDependentVowel, "accents": types.RoleOther},
+		},
+		Categories: map[string]types.Section{
+			"vyanjan": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"क"}, RHS: []string{"k"}},
+				{LHS: []string{"म"}, RHS: []string{"m"}},
+			})},
+			"signs": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"ि"}, RHS: []string{"i"}},
+				{LHS: []string{"्"}, RHS: []string{"\u0000"}},
+			})},
+			"accents": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"॒"}, RHS: []string{"\\_"}},
+			})},
+		},
+	}
+
+	session, err := newSession(types.CompileScheme(forward))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	output, err := session.Transliterate("kmi\\_ka")
+	if err != nil {
+		t.Fatalf("Transliteration failed: %v", err)
+	}
+	if output != "क्मि॒क" {
+		t.Errorf("Expected %q, got %q", "क्मि॒क", output)
+	}
+
+	session, err = newSession(types.CompileScheme(reverse))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	output, err = session.Reversliterate("क्मि॒क")
+	if err != nil {
+		t.Fatalf("Reversliteration failed: %v", err)
+	}
+	if output != "kmi\\_k" {
+		t.Errorf("Expected %q, got %q", "kmi\\_k", output)
+	}
+}
+
+// TestReversliterateNormalization verifies that precomposed and decomposed
+// spellings of nukta letters reversliterate alike.
+func TestReversliterateNormalization(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id       string
+		inputs   []string
+		expected string
+	}{
+		{"rhindi", []string{"\u095B\u0930\u093E", "\u091C\u093C\u0930\u093E"}, "zaraa"},
+		{"rhindi", []string{"\u0929", "\u0928\u093C"}, "^n"},
+		{"riso15919", []string{"\u095C\u0940", "\u0921\u093C\u0940"}, "ṛī"},
+	}
+
+	for _, test := range tests {
+		for _, input := range test.inputs {
+			output, err := aks.ReversliterateWithKeymap(test.id, input)
+			if err != nil {
+				t.Fatalf("Reversliteration failed: %v", err)
+			}
+			if output != test.expected {
+				t.Errorf("%s: for input %q, expected %q, got %q", test.id, input, test.expected, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package translit
+
+import (
+	"testing"
+
+	"aks.go/internal/keymap"
+	"aks.go/internal/norm"
+)
+
+// TestRomanizationKeymaps verifies the IAST and ISO 15919 keymaps in both
+// directions, with precomposed and combining-mark input alike.
+func TestRomanizationKeymaps(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	forwardTests := []struct {
+		keymap   string
+		input    string
+		expected string
+	}{
+		{"iast", "saṃskṛtam", "संस्कृतम्"},
+		{"iast", "kṛṣṇaḥ", "कृष्णः"},
+		{"iast", "ṝṣi", "ॠषि"},
+		{"iast", "Rāmaḥ vanaṃ gacchati |", "रामः वनं गच्छति ।"},
+		{"iast", "rāmam", "रामम्"},
+		{"iast", "so'ham", "सोऽहम्"},
+		{"iast", "jñānaṃ 108", "ज्ञानं १०८"},
+		{"iso15919", "hindī", "हिन्दी"},
+		{"iso15919", "kr̥ṣṇa", "कृष्ण"},
+		{"iso15919", "pēṛ", "पे\u095C्"},
+		{"iso15919", "ḍôkṭara", "डॉक्टर"},
+		{"iso15919", "k͟hāna", "\u0959ान"},
+		{"iso15919", "toṭṭi", "तॊट्टि"},
+	}
+
+	for _, test := range forwardTests {
+		for _, input := range []string{test.input, norm.NFD(test.input)} {
+			output, err := aks.TransliterateWithKeymap(test.keymap, input)
+			if err != nil {
+				t.Errorf("%s: error transliterating %q: %v", test.keymap, input, err)
+				continue
+			}
+			if output != test.expected {
+				t.Errorf("%s: for input %q, expected %q but got %q", test.keymap, input, test.expected, output)
+			}
+		}
+	}
+
+	reverseTests := []struct {
+		keymap   string
+		input    string
+		expected string
+	}{
+		{"riast", "संस्कृतम्", "saṃskṛtam"},
+		{"riast", "कृष्णः", "kṛṣṇaḥ"},
+		{"riast", "सोऽहम्", "so'ham"},
+		{"riast", "ॐ नमः शिवाय ।", "oṃ namaḥ śivāya |"},
+		{"riast", "पे\u095C", "peṙa"},
+		{"riast", "ख\u093Cान", "k͟hāna"}, // Decomposed nukta
+		{"riso15919", "हिन्दी", "hindī"},
+		{"riso15919", "कृष्ण", "kr̥ṣṇa"},
+		{"riso15919", "पे\u095C", "pēṛa"},
+		{"riso15919", "डॉक्टर", "ḍôkṭara"},
+		{"riso15919", "ख\u093Cान", "k͟hāna"}, // Decomposed nukta,
+	}
+
+	for _, test := range reverseTests {
+		output, err := aks.TransliterateWithKeymap(test.keymap, test.input)
+		if err != nil {
+			t.Errorf("%s: error reversliterating %q: %v", test.keymap, test.input, err)
+			continue
+		}
+		if output != test.expected {
+			t.Errorf("%s: for input %q, expected %q but got %q", test.keymap, test.input, test.expected, output)
+		}
+	}
+}
+
+// TestRomanizationNuktaRoundTrip verifies that nukta letters, precomposed or
+// decomposed, come back from IAST as they went in, in the keymap's
+// normalization form.
+func TestRomanizationNuktaRoundTrip(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	const input = "\u0958\u0932\u092E \u0959\u093E\u0928 \u095A\u0932 \u095B\u0930\u093E \u092A\u0947\u095C \u092A\u0922\u093C\u093E\u0908 \u095E\u0930\u094D\u095B \u095F\u0941\u0917"
+	for _, text := range []string{input, norm.NFD(input)} {
+		romanized, err := aks.TransliterateWithKeymap("riast", text)
+		if err != nil {
+			t.Fatalf("Reversliteration failed: %v", err)
+		}
+		output, err := aks.TransliterateWithKeymap("iast", romanized)
+		if err != nil {
+			t.Fatalf("Transliteration failed: %v", err)
+		}
+		if output != norm.NFC(input) {
+			t.Errorf("For input %q, expected %q through %q, got %q", text, norm.NFC(input), romanized, output)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package translit
+
+import (
+	"fmt"
+	"strings"
+
+	"aks.go/internal/types"
+)
+
+// RoundTripMismatch is an input whose round trip forward→reverse→forward does
+// not reproduce the output of the first forward mapping.
+type RoundTripMismatch struct {
+	Input   string `json:"input"`
+	Forward string `json:"forward"` // The input mapped by the forward keymap
+	Reverse string `json:"reverse"` // Forward mapped back by the reverse keymap
+	Result  string `json:"result"`  // Reverse mapped again by the forward keymap
+	Segment string `json:"segment"` // The segment of Forward at which Result first differs
+	Pivot   string `json:"pivot"`   // What the reverse keymap made of Segment
+}
+
+// RoundTrip maps every input through the forward keymap, back through the
+// reverse keymap and through the forward keymap again, and returns the inputs
+// whose result does not stabilize. Inputs are romanized text for the forward
+// keymap, typically single words.
+func (a *Aksharamala) RoundTrip(forwardID, reverseID string, inputs []string) ([]RoundTripMismatch, error) {
+	forward, err := a.NewSession(forwardID)
+	if err != nil {
+		return nil, err
+	}
+	reverse, err := a.NewSession(reverseID)
+	if err != nil {
+		return nil, err
+	}
+	if isReverse(forward.compiled) || !isReverse(reverse.compiled) {
+		return nil, fmt.Errorf("'%s' must take romanized input and '%s' native script", forwardID, reverseID)
+	}
+
+	var mismatches []RoundTripMismatch
+	for _, input := range inputs {
+		first, err := forward.Transliterate(input)
+		if err != nil {
+			return nil, err
+		}
+		back, err := reverse.ReversliterateAligned(first)
+		if err != nil {
+			return nil, err
+		}
+		again, err := forward.Transliterate(back.Output)
+		if err != nil {
+			return nil, err
+		}
+		if again == first {
+			continue
+		}
+
+		mismatch := RoundTripMismatch{Input: input, Forward: first, Reverse: back.Output, Result: again}
+		if segment, ok := divergingSegment(back.Alignment, first, again); ok {
+			firstRunes, reverseRunes := []rune(first), []rune(back.Output)
+			mismatch.Segment = string(firstRunes[segment.Input.Start:segment.Input.End])
+			mismatch.Pivot = string(reverseRunes[segment.Output.Start:segment.Output.End])
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches, nil
+}
+
+// divergingSegment returns the segment of the reverse alignment that covers
+// the first rune at which again differs from first.
+func divergingSegment(alignment []Segment, first, again string) (Segment, bool) {
+	if len(alignment) == 0 {
+		return Segment{}, false
+	}
+
+	firstRunes, againRunes := []rune(first), []rune(again)
+	k := 0
+	for k < len(firstRunes) && k < len(againRunes) && firstRunes[k] == againRunes[k] {
+		k++
+	}
+	for _, segment := range alignment {
+		if k < segment.Input.End {
+			return segment, true
+		}
+	}
+	return alignment[len(alignment)-1], true
+}
+
+// Aksharas enumerates romanized inputs for every single akshara of a forward
+// keymap: each consonant followed by each vowel that has a matra, including the
+// inherent vowel, and each consonant with a virama. The virama is typed with
+// the keymap's explicit virama mapping if it has one; in normal mode a bare
+// consonant also ends in a virama.
+func (a *Aksharamala) Aksharas(id string) ([]string, error) {
+	compiled, exists := a.keymapStore.GetCompiled(id)
+	if !exists {
+		return nil, fmt.Errorf("keymap with ID '%s' not found", id)
+	}
+	if isReverse(compiled) {
+		return nil, fmt.Errorf("'%s' does not take romanized input", id)
+	}
+	virama, mode, err := types.ParseVirama(compiled.Scheme.Metadata.Virama)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse virama: %v", err)
+	}
+
+	var consonants, matras, viramas []string
+	for _, category := range compiled.Scheme.CategoryNames() {
+		section := compiled.Scheme.Categories[category]
+		for _, mapping := range section.Mappings.All() {
+			if len(mapping.LHS) == 0 || len(mapping.RHS) == 0 || types.IsControlOutput(mapping.RHS[0]) {
+				continue
+			}
+			// A reph, which writes text only through its rules, is no akshara of its own
+			if base, _ := types.ParseContextualRules(mapping.RHS[0]); base == "" {
+				continue
+			}
+			role := compiled.Role(category)
+			switch {
+			case role == types.RoleConsonant:
+				consonants = append(consonants, mapping.LHS[0])
+			case role == types.RoleIndependentVowel && len(mapping.RHS) > 1:
+				matras = append(matras, mapping.LHS[0])
+			case strings.HasPrefix(mapping.RHS[0], virama):
+				viramas = append(viramas, mapping.LHS[0])
+			}
+		}
+	}
+	if mode == types.NormalMode {
+		viramas = append(viramas, "")
+	}
+
+	var aksharas []string
+	for _, consonant := range consonants {
+		for _, matra := range matras {
+			aksharas = append(aksharas, consonant+matra)
+		}
+		for _, virama := range viramas {
+			aksharas = append(aksharas, consonant+virama)
+		}
+	}
+	return aksharas, nil
+}
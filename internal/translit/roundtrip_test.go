@@ -0,0 +1,105 @@
+package translit
+
+import (
+	"testing"
+
+	"aks.go/internal/keymap"
+)
+
+// TestRoundTrip verifies that forward and reverse keymap pairs agree on every
+// single akshara, and that drift is reported with the segment that caused it.
+func TestRoundTrip(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	pairs := []struct{ forward, reverse string }{
+		{"hindi", "rhindi"},
+		{"iast", "riast"},
+		{"iso15919", "riso15919"},
+	}
+	for _, pair := range pairs {
+		t.Run(pair.forward, func(t *testing.T) {
+			aksharas, err := aks.Aksharas(pair.forward)
+			if err != nil {
+				t.Fatalf("Failed to enumerate aksharas: %v", err)
+			}
+			mismatches, err := aks.RoundTrip(pair.forward, pair.reverse, aksharas)
+			if err != nil {
+				t.Fatalf("Round trip failed: %v", err)
+			}
+			for _, m := range mismatches {
+				t.Errorf("%q did not round-trip: %+v", m.Input, m)
+			}
+		})
+	}
+
+	// RSanskrit has no spelling for an explicit virama or a ZWJ
+	mismatches, err := aks.RoundTrip("hindi", "rsanskrit", []string{"namaste", "k.h", "daRyaa"})
+	if err != nil {
+		t.Fatalf("Round trip failed: %v", err)
+	}
+	want := []RoundTripMismatch{
+		{Input: "k.h", Forward: "क्‌", Reverse: "k", Result: "क", Segment: "्", Pivot: ""},
+		{Input: "daRyaa", Forward: "दर्‍या", Reverse: "daryaa", Result: "दर्या", Segment: "‍", Pivot: ""},
+	}
+	if len(mismatches) != len(want) {
+		t.Fatalf("Expected mismatches %+v, got %+v", want, mismatches)
+	}
+	for i := range want {
+		if mismatches[i] != want[i] {
+			t.Errorf("Mismatch %d: expected %+v, got %+v", i, want[i], mismatches[i])
+		}
+	}
+
+	if _, err := aks.RoundTrip("rhindi", "hindi", nil); err == nil {
+		t.Error("Expected an error for swapped keymaps")
+	}
+}
+
+// TestAksharas verifies the enumeration of single aksharas in smart and normal
+// virama modes.
+func TestAksharas(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id      string
+		present []string
+		absent  []string
+	}{
+		{"hindi", []string{"ka", "kaa", "kR^i", "k.h"}, []string{"k", ".ra"}},
+		{"iast", []string{"ka", "kā", "k"}, nil},
+	}
+	for _, tt := range tests {
+		aksharas, err := aks.Aksharas(tt.id)
+		if err != nil {
+			t.Fatalf("Failed to enumerate aksharas for %s: %v", tt.id, err)
+		}
+		set := make(map[string]bool)
+		for _, akshara := range aksharas {
+			set[akshara] = true
+		}
+		for _, akshara := range tt.present {
+			if !set[akshara] {
+				t.Errorf("%s: expected %q among the aksharas", tt.id, akshara)
+			}
+		}
+		for _, akshara := range tt.absent {
+			if set[akshara] {
+				t.Errorf("%s: did not expect %q among the aksharas", tt.id, akshara)
+			}
+		}
+	}
+
+	if _, err := aks.Aksharas("rhindi"); err == nil {
+		t.Error("Expected an error for a reverse keymap")
+	}
+}
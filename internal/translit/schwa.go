@@ -0,0 +1,185 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+
+	"aks.go/internal/core"
+	"aks.go/internal/types"
+)
+
+// schwaToken is a token of a native-script word as seen by schwa deletion.
+type schwaToken struct {
+	start int  // Rune offset of the token in the input
+	kind  byte // One of the schwa token kinds below
+}
+
+// Schwa token kinds.
+const (
+	tokenConsonant = 'C' // A consonant
+	tokenVowel     = 'V' // An independent vowel or a vowel sign
+	tokenHalant    = 'H' // A virama, which removes the inherent vowel
+	tokenOther     = 'O' // Anything else, such as an anusvara or visarga
+)
+
+// SetSchwaDeletion turns schwa deletion on or off for reversliteration with
+// this session, overriding the keymap's "schwa" metadata.
+func (s *Session) SetSchwaDeletion(enabled bool) {
+	s.schwaDeletion = enabled
+}
+
+// parseSchwaExceptions indexes exception words by their text without the "+"
+// marks, recording the rune offsets of the morpheme boundaries the marks denote.
+func parseSchwaExceptions(exceptions []string) map[string][]int {
+	if len(exceptions) == 0 {
+		return nil
+	}
+	parsed := make(map[string][]int, len(exceptions))
+	for _, exception := range exceptions {
+		var boundaries []int
+		offset := 0
+		for _, morpheme := range strings.Split(exception, "+") {
+			offset += len([]rune(morpheme))
+			boundaries = append(boundaries, offset)
+		}
+		parsed[strings.ReplaceAll(exception, "+", "")] = boundaries[:len(boundaries)-1]
+	}
+	return parsed
+}
+
+// schwaDeleted reports whether the inherent vowel of the consonant at rune
+// offset i is deleted, and why. The decisions for a word are made once, when
+// its first consonant is reached, and cached until the input changes.
+func (s *Session) schwaDeleted(runes []rune, i int) (bool, string) {
+	if i < s.schwaWord.Start || i >= s.schwaWord.End {
+		start, end := wordBounds(runes, i)
+		s.schwaWord = Span{Start: start, End: end}
+		s.schwaDeletions = s.analyzeSchwa(runes, start, end)
+	}
+	reason, deleted := s.schwaDeletions[i]
+	if !deleted {
+		return false, "schwa is pronounced"
+	}
+	return true, reason
+}
+
+// analyzeSchwa decides which inherent vowels of the word runes[start:end] are
+// deleted and returns the reasons keyed by the rune offset of their consonant.
+// A word listed in the keymap's exceptions is analyzed one morpheme at a time.
+func (s *Session) analyzeSchwa(runes []rune, start, end int) map[int]string {
+	deletions := make(map[int]string)
+	from := start
+	for _, boundary := range s.schwaExceptions[string(runes[start:end])] {
+		s.analyzeMorpheme(runes, from, start+boundary, deletions, "schwa deleted at a morpheme boundary")
+		from = start + boundary
+	}
+	s.analyzeMorpheme(runes, from, end, deletions, "schwa deleted at the end of a word")
+	return deletions
+}
+
+// analyzeMorpheme applies schwa deletion to runes[start:end]. The final schwa
+// is deleted with finalReason. Going from right to left, a medial schwa is
+// deleted when a vowel and a single consonant precede it and a single
+// consonant and a pronounced vowel follow it (VC_CV); a schwa whose deletion
+// would join three consonants stays, as does the schwa of a word's first consonant.
+func (s *Session) analyzeMorpheme(runes []rune, start, end int, deletions map[int]string, finalReason string) {
+	tokens := s.schwaTokens(runes, start, end)
+
+	// hasSchwa reports whether token k is a consonant with an inherent vowel
+	hasSchwa := func(k int) bool {
+		if tokens[k].kind != tokenConsonant {
+			return false
+		}
+		return k+1 == len(tokens) || (tokens[k+1].kind != tokenVowel && tokens[k+1].kind != tokenHalant)
+	}
+	// voiced reports whether token k is a vowel or a consonant whose schwa is kept
+	voiced := func(k int) bool {
+		if hasSchwa(k) {
+			_, deleted := deletions[tokens[k].start]
+			return !deleted
+		}
+		return tokens[k].kind == tokenVowel
+	}
+
+	for k := len(tokens) - 1; k > 0; k-- {
+		if !hasSchwa(k) {
+			continue
+		}
+		if k+1 == len(tokens) {
+			deletions[tokens[k].start] = finalReason
+			continue
+		}
+
+		next := k + 1
+		if !voiced(k-1) || tokens[next].kind != tokenConsonant {
+			continue
+		}
+		if (next+1 < len(tokens) && tokens[next+1].kind == tokenVowel) || voiced(next) {
+			deletions[tokens[k].start] = "medial schwa between single consonants (VC_CV)"
+		}
+	}
+}
+
+// schwaTokens splits runes[start:end] into the tokens of the keymap.
+func (s *Session) schwaTokens(runes []rune, start, end int) []schwaToken {
+	var tokens []schwaToken
+	for i := start; i < end; {
+		length, kind := 1, byte(tokenOther)
+		if matches := s.compiled.MatchRunes(runes[:end], i); len(matches) > 0 {
+			length = matches[0].Length
+			kind = s.schwaKind(runes[i], matches[0].Entries)
+		}
+		tokens = append(tokens, schwaToken{start: i, kind: kind})
+		i += length
+	}
+	return tokens
+}
+
+// schwaKind classifies a token by the role of its mapping's category.
+func (s *Session) schwaKind(first rune, entries []core.TrieEntry) byte {
+	for _, entry := range entries {
+		if len(entry.RHS) == 0 {
+			continue
+		}
+		switch s.compiled.Role(entry.Category) {
+		case types.RoleConsonant:
+			return tokenConsonant
+		case types.RoleIndependentVowel:
+			return tokenVowel
+		case types.RoleDependentVowel:
+			if isVirama(first) {
+				return tokenHalant
+			}
+			return tokenVowel
+		}
+		return tokenOther
+	}
+	return tokenOther
+}
+
+// isVirama reports whether r is the virama sign of an Indic script.
+func isVirama(r rune) bool {
+	switch r {
+	case '्', '্', '੍', '્', '୍', '்', '్', '್', '്':
+		return true
+	}
+	return false
+}
+
+// wordBounds returns the rune offsets of the word around offset i. A word is
+// a run of letters, marks and joiners.
+func wordBounds(runes []rune, i int) (int, int) {
+	start, end := i, i
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+	return start, end
+}
+
+// isWordRune reports whether r can be part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r) || r == '‌' || r == '‍'
+}
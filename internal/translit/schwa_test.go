@@ -0,0 +1,104 @@
+package translit
+
+import (
+	"strings"
+	"testing"
+
+	"aks.go/internal/keymap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchwaDeletion(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"कमरा", "kamraa"},     // VC_CV
+		{"समझना", "samajhnaa"}, // Right to left, so only one of two candidates goes
+		{"बचपन", "bachpan"},
+		{"अपना", "apnaa"},
+		{"सरकार", "sarkaar"},
+		{"लड़की", "la.DkI"},
+		{"कमल", "kamal"},        // VC_CV fails: the final schwa of ल is deleted, so no vowel follows it
+		{"नमस्ते", "namaste"},   // A cluster follows
+		{"न", "na"},             // The first consonant keeps its schwa
+		{"रामनगर", "raamangar"}, // Not an exception: one word
+		{"राम का घर", "raam kaa ghar"},
+	}
+
+	session, err := aks.NewSession("rhindi")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session.SetSchwaDeletion(true)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			output, err := session.Reversliterate(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+
+	// Exceptions are analyzed per morpheme
+	session.schwaExceptions = parseSchwaExceptions([]string{"राम+नगर"})
+	output, err := session.Reversliterate("रामनगर में")
+	assert.NoError(t, err)
+	assert.Equal(t, "raamnagar meM", output)
+
+	// Strict mode keeps today's output
+	session.SetSchwaDeletion(false)
+	output, err = session.Reversliterate("कमरा")
+	assert.NoError(t, err)
+	assert.Equal(t, "kamaraa", output)
+}
+
+func TestSchwaDeletionTrace(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	session, err := NewAksharamala(store).NewSession("rhindi")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session.SetSchwaDeletion(true)
+
+	trace, err := session.Explain("कमल")
+	assert.NoError(t, err)
+	var reasons []string
+	for _, step := range trace.Steps {
+		reasons = append(reasons, step.ViramaReason)
+	}
+	assert.Equal(t, []string{
+		"schwa is pronounced",
+		"schwa is pronounced",
+		"schwa deleted at the end of a word",
+	}, reasons)
+}
+
+func TestSchwaDeletionStream(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	session, err := NewAksharamala(store).NewSession("rhindi")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session.SetSchwaDeletion(true)
+
+	input := strings.Repeat("समझना बचपन कमरा ", 4000)
+	expected, err := session.Reversliterate(input)
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, session.ReversliterateStream(strings.NewReader(input), &out))
+	assert.Equal(t, expected, out.String())
+	assert.True(t, strings.HasPrefix(expected, "samajhnaa bachpan kamraa "))
+}
@@ -0,0 +1,99 @@
+package translit
+
+import (
+	"fmt"
+
+	"aks.go/internal/types"
+)
+
+// Session carries the state of transliteration runs with a single keymap.
+// The compiled keymap it points to is shared and read-only; the context and
+// virama handler belong to the session alone. A Session is cheap to create,
+// may be reused for consecutive calls, and must not be shared between goroutines.
+type Session struct {
+	scheme        *types.TransliterationScheme
+	compiled      *types.CompiledScheme
+	context       *types.Context
+	viramaHandler *types.ViramaHandler
+	mark          int // Output offset where the latest step started writing
+
+	tracing bool        // Whether steps are recorded, see Explain
+	steps   []TraceStep // Steps recorded while tracing
+
+	aligning bool      // Whether segments are recorded, see TransliterateAligned
+	segments []Segment // Segments recorded while aligning, with output spans in bytes
+	lead     int       // Bytes the latest step wrote for the previous token, such as a virama
+	rewrite  int       // Output offset from which the latest step changed the output
+
+	collecting bool  // Whether unmatched input is recorded, see mapUnmatched
+	unmatched  []int // Rune offsets of the input no mapping matched, recorded while collecting
+
+	schwaDeletion   bool             // Whether silent inherent vowels are dropped when reversliterating
+	schwaExceptions map[string][]int // Morpheme boundaries of exception words, see parseSchwaExceptions
+	schwaWord       Span             // Word whose schwa deletions are cached
+	schwaDeletions  map[int]string   // Reasons for the deleted schwas of schwaWord, by consonant offset
+}
+
+// NewSession creates a Session for the keymap with the given ID, in its latest
+// version, or for the version named by "id@version". The keymap a session
+// holds stays the same when the store loads another version.
+func (a *Aksharamala) NewSession(id string) (*Session, error) {
+	compiled, exists := a.keymapStore.GetCompiled(id)
+	if !exists {
+		return nil, fmt.Errorf("keymap with ID '%s' not found", id)
+	}
+	return newSession(compiled)
+}
+
+// newSession creates a Session for a compiled keymap.
+func newSession(compiled *types.CompiledScheme) (*Session, error) {
+	virama, viramaMode, err := types.ParseVirama(compiled.Scheme.Metadata.Virama)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse virama: %v", err)
+	}
+
+	ctx := types.NewContext()
+	ctx.Compiled = compiled
+	return &Session{
+		scheme:          &compiled.Scheme,
+		compiled:        compiled,
+		context:         ctx,
+		viramaHandler:   types.NewViramaHandler(viramaMode, virama, ctx),
+		schwaDeletion:   compiled.Scheme.Metadata.Schwa == types.SchwaDelete,
+		schwaExceptions: parseSchwaExceptions(compiled.Scheme.Metadata.SchwaExceptions),
+	}, nil
+}
+
+// KeymapID returns the ID of the session's keymap.
+func (s *Session) KeymapID() string {
+	return s.scheme.ID
+}
+
+// Version returns the version of the session's keymap, empty if it has none.
+// Passed as "id@version", it names the exact keymap that produces the
+// session's output.
+func (s *Session) Version() string {
+	return s.scheme.Version
+}
+
+// Map maps input in the direction of the session's keymap: reversliteration
+// for Unicode keymaps and transliteration for romanized input schemes.
+func (s *Session) Map(input string) (string, error) {
+	switch s.scheme.Scheme {
+	case types.SchemeUnicode:
+		return s.Reversliterate(input)
+	case types.SchemeITRANS, types.SchemeRTS, types.SchemeIAST, types.SchemeISO15919:
+		return s.Transliterate(input)
+	}
+	return "", fmt.Errorf("unsupported scheme: %s", s.scheme.Scheme)
+}
+
+// reset prepares the session for a new input.
+func (s *Session) reset(input string) {
+	s.context = types.NewContext()
+	s.context.Compiled = s.compiled
+	s.context.SetInput(input)
+	s.viramaHandler = types.NewViramaHandler(s.viramaHandler.Mode, s.viramaHandler.Virama, s.context)
+	s.schwaWord = Span{}
+	s.schwaDeletions = nil
+}
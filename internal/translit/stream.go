@@ -0,0 +1,190 @@
+package translit
+
+import (
+	"io"
+	"unicode/utf8"
+
+	"aks.go/internal/types"
+)
+
+// streamChunkSize is the number of bytes requested from the reader per refill.
+const streamChunkSize = 32 * 1024
+
+// streamWordLength is the length in runes of the longest word schwa deletion
+// waits for when streaming; a longer word is cut at the window's limit.
+const streamWordLength = 1024
+
+// streamProcessor consumes as much of window as it safely can, writing the
+// output to result, and returns the number of bytes consumed. When final is
+// true the window holds the rest of the input and must be consumed entirely.
+type streamProcessor func(window string, final bool, result *types.Output) int
+
+// TransliterateStream reads input from r and writes its transliteration to w.
+// Memory use is bounded by the chunk size plus the longest LHS of the keymap.
+// The context marker, pending virama and separator lookahead carry across
+// chunk boundaries, so the output matches Transliterate on the whole input.
+func (s *Session) TransliterateStream(r io.Reader, w io.Writer) error {
+	s.reset("")
+	return s.stream(r, w, func(window string, final bool, result *types.Output) int {
+		runes := []rune(window)
+		limit := len(runes)
+		if !final {
+			limit = s.holdWord(runes, limit-s.compiled.MaxLHSLength())
+		}
+
+		s.context.SetInput(window)
+		i := 0
+		for i < limit {
+			i = s.transliterateStep(runes, i, result)
+		}
+		if final {
+			s.finishTransliterate(result)
+		}
+		s.carry(runes, i)
+		return runeOffset(window, i)
+	})
+}
+
+// ReversliterateStream reads input from r and writes its reversliteration to w.
+// It has the same memory bounds and chunk-boundary guarantees as TransliterateStream.
+func (s *Session) ReversliterateStream(r io.Reader, w io.Writer) error {
+	s.reset("")
+	return s.stream(r, w, func(window string, final bool, result *types.Output) int {
+		runes := []rune(window)
+		limit := len(runes)
+		if !final {
+			limit = s.holdWord(runes, limit-s.compiled.MaxLHSLength())
+		}
+
+		if s.schwaDeletion && !final {
+			limit = holdSchwaWord(runes, limit)
+		}
+
+		s.context.SetInput(window)
+		s.schwaWord = Span{}
+		i := 0
+		for i < limit {
+			i = s.reversliterateStep(runes, i, result)
+		}
+		s.carry(runes, i)
+		return runeOffset(window, i)
+	})
+}
+
+// stream feeds r to process in bounded windows and writes finished output to w.
+func (s *Session) stream(r io.Reader, w io.Writer, process streamProcessor) error {
+	var window []byte
+	var result types.Output
+	chunk := make([]byte, streamChunkSize)
+
+	for {
+		n, err := r.Read(chunk)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		window = append(window, chunk[:n]...)
+		final := err == io.EOF
+
+		// Hold back an incomplete trailing rune until the rest of it arrives
+		usable := len(window)
+		if !final {
+			usable = completePrefix(window)
+		}
+
+		consumed := process(string(window[:usable]), final, &result)
+		window = append(window[:0], window[consumed:]...)
+
+		if err := s.flush(w, &result, final); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// flush writes finished output to w. Unless final, the output of the latest
+// step stays buffered, together with as many units before it as a contextual
+// rule of the keymap may replace, because the next steps may still rewrite them.
+func (s *Session) flush(w io.Writer, result *types.Output, final bool) error {
+	done := result.Len()
+	if !final {
+		done = min(s.mark, result.UnitStart(s.compiled.MaxReplace()+1))
+	}
+
+	if _, err := io.WriteString(w, result.Cut(done)); err != nil {
+		return err
+	}
+	s.mark = 0
+	return nil
+}
+
+// holdWord returns limit, or the start of the word runes end with if that is
+// earlier and the word may be in the keymap's dictionary, so that the word is
+// looked up once all of it has arrived.
+func (s *Session) holdWord(runes []rune, limit int) int {
+	if s.compiled.MaxWordLength() == 0 {
+		return limit
+	}
+	start := len(runes)
+	for start > 0 && !types.IsSeparatorRune(runes[start-1]) {
+		start--
+	}
+	if len(runes)-start > s.compiled.MaxWordLength() {
+		return limit
+	}
+	return min(limit, start)
+}
+
+// holdSchwaWord returns limit, moved back to the start of a word that crosses
+// it or, when limit is the end of runes, ends there: schwa deletion looks at
+// whole words, and the next window may continue the word. A word longer than
+// streamWordLength is cut at limit, so that the window stays bounded.
+func holdSchwaWord(runes []rune, limit int) int {
+	if limit <= 0 {
+		return limit
+	}
+	if limit >= len(runes) {
+		limit = len(runes)
+		if !isWordRune(runes[limit-1]) {
+			return limit
+		}
+	} else if !isWordRune(runes[limit]) {
+		return limit
+	}
+	start, end := wordBounds(runes, limit)
+	if end-start > streamWordLength {
+		return limit
+	}
+	return start
+}
+
+// carry keeps the last of the first n runes as the rune before the next window.
+func (s *Session) carry(runes []rune, n int) {
+	if n > 0 {
+		s.context.Previous = runes[n-1]
+	}
+}
+
+// runeOffset returns the byte offset of the n-th rune in s.
+func runeOffset(s string, n int) int {
+	offset := 0
+	for ; n > 0 && offset < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// completePrefix returns the length of b without a trailing, incomplete UTF-8 sequence.
+func completePrefix(b []byte) int {
+	for k := 1; k < utf8.UTFMax && k <= len(b); k++ {
+		if utf8.RuneStart(b[len(b)-k]) {
+			if !utf8.FullRune(b[len(b)-k:]) {
+				return len(b) - k
+			}
+			break
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,207 @@
+package translit
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"aks.go/internal/core"
+	"aks.go/internal/keymap"
+	"aks.go/internal/types"
+)
+
+// TestStreamMatchesWholeInput verifies that the streaming APIs produce the same
+// output as the whole-string APIs, even when every read returns a single byte
+// and chunk boundaries fall inside matches and multi-byte characters.
+func TestStreamMatchesWholeInput(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	if err := store.LoadDictionary("../../keymaps/dictionaries/Hindi.aksd"); err != nil {
+		t.Fatalf("Failed to load dictionary: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id      string
+		input   string
+		reverse bool
+	}{
+		{"hindi", "yah ek su.ndar din hai. aaj ham bahut khush hai.n.", false},
+		{"teluguRts", "jeevitam aaScharyaala tO niMDinadi. a1k avasaram.", false},
+		{"teluguRts", "kk", false},
+		{"teluguRts", "nEnu \\#Go code\\# raastaanu", false},
+		{"rhindi", "नमस्ते, संस्कृत हिंदी क्षमा", true},
+		{"hindi", "Google par Delhi, mumbai aur computer", false},
+		{"rhindi", "गूगल पर दिल्ली, मुंबई", true},
+		{"rsanskrit", "धर्म कृष्ण अग्निः देवाः गङ्गा", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			session, err := aks.NewSession(test.id)
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+
+			whole, convert := session.Transliterate, session.TransliterateStream
+			if test.reverse {
+				whole, convert = session.Reversliterate, session.ReversliterateStream
+			}
+
+			expected, err := whole(test.input)
+			if err != nil {
+				t.Fatalf("Whole-input conversion failed: %v", err)
+			}
+
+			readers := map[string]io.Reader{
+				"full":     strings.NewReader(test.input),
+				"one-byte": iotest.OneByteReader(strings.NewReader(test.input)),
+			}
+			for name, reader := range readers {
+				var out strings.Builder
+				if err := convert(reader, &out); err != nil {
+					t.Fatalf("%s: streaming failed: %v", name, err)
+				}
+				if out.String() != expected {
+					t.Errorf("%s: expected %q, got %q", name, expected, out.String())
+				}
+			}
+		})
+	}
+}
+
+// TestStreamLargeInput verifies streaming across many full chunks.
+func TestStreamLargeInput(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	session, err := NewAksharamala(store).NewSession("hindi")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	input := strings.Repeat("aur ab ham tayyaar hai.n naye anubhavo.n ke liye. ", 5000)
+	expected, _ := session.Transliterate(input)
+
+	var out strings.Builder
+	if err := session.TransliterateStream(iotest.HalfReader(strings.NewReader(input)), &out); err != nil {
+		t.Fatalf("Streaming failed: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("Streaming output differs from whole-input output (%d vs %d bytes)",
+			out.Len(), len(expected))
+	}
+}
+
+// TestStreamContextualRules verifies that rules replacing earlier output work
+// the same when the output they replace was written in an earlier chunk, and
+// that rules testing the start of a word see the input of earlier chunks.
+func TestStreamContextualRules(t *testing.T) {
+	scheme := types.TransliterationScheme{
+		ID:       "replace",
+		Scheme:   types.SchemeITRANS,
+		Metadata: types.Metadata{Virama: "्, smart"},
+		Categories: map[string]types.Section{
+			"consonants": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"k"}, RHS: []string{"क"}},
+					{LHS: []string{"n"}, RHS: []string{"न(next:k c)ङ"}},
+				}),
+			},
+			"others": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"q"}, RHS: []string{"(prev:consonants u3)ॐ"}},
+					{LHS: []string{"i"}, RHS: []string{"ि(initial u)इ"}},
+				}),
+			},
+		},
+	}
+
+	session, err := newSession(types.CompileScheme(scheme))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"kkkq", "क्ॐ"},
+		{"nk kkkkq q", "ङ्क क्क्ॐ "},
+		{"ki ik", "कि इक"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			whole, err := session.Transliterate(tt.input)
+			if err != nil {
+				t.Fatalf("Transliteration failed: %v", err)
+			}
+			if whole != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, whole)
+			}
+
+			var out strings.Builder
+			if err := session.TransliterateStream(iotest.OneByteReader(strings.NewReader(tt.input)), &out); err != nil {
+				t.Fatalf("Streaming failed: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Streaming: expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+// TestStreamEmptyScheme verifies that keymaps without mappings, whose longest
+// LHS is 0 runes long, stream their input unchanged, with schwa deletion too.
+func TestStreamEmptyScheme(t *testing.T) {
+	for _, scheme := range []string{types.SchemeITRANS, types.SchemeUnicode} {
+		t.Run(scheme, func(t *testing.T) {
+			session, err := newSession(types.CompileScheme(types.TransliterationScheme{
+				ID:         "empty",
+				Scheme:     scheme,
+				Metadata:   types.Metadata{Virama: "्, smart"},
+				Categories: map[string]types.Section{"consonants": {}},
+			}))
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+			session.SetSchwaDeletion(true)
+
+			convert := session.TransliterateStream
+			if scheme == types.SchemeUnicode {
+				convert = session.ReversliterateStream
+			}
+			input := "कमल ka mal"
+			var out strings.Builder
+			if err := convert(iotest.OneByteReader(strings.NewReader(input)), &out); err != nil {
+				t.Fatalf("Streaming failed: %v", err)
+			}
+			if out.String() != input {
+				t.Errorf("Expected %q, got %q", input, out.String())
+			}
+		})
+	}
+}
+
+// TestStreamSchwaLongWord verifies that schwa deletion streams a word longer
+// than it waits for, instead of holding it until the end of input.
+func TestStreamSchwaLongWord(t *testing.T) {
+	runes := []rune(strings.Repeat("क", streamWordLength+1))
+	if limit := holdSchwaWord(runes, len(runes)-1); limit != len(runes)-1 {
+		t.Errorf("Expected a long word to be cut at %d, got %d", len(runes)-1, limit)
+	}
+	runes = []rune("राम कमल")
+	if limit := holdSchwaWord(runes, len(runes)); limit != 4 {
+		t.Errorf("Expected the last word to be held from 4, got %d", limit)
+	}
+	if limit := holdSchwaWord(runes, 3); limit != 3 {
+		t.Errorf("Expected a limit after a word to stay 3, got %d", limit)
+	}
+}
@@ -0,0 +1,179 @@
+package translit
+
+import "aks.go/internal/types"
+
+// Trace actions describe what a step did with its input span.
+const (
+	ActionMatch         = "match"          // A mapping matched and its output was written
+	ActionWord          = "word"           // A whole word of the dictionary was written
+	ActionSpace         = "space"          // A space, with any pending virama
+	ActionSyllableBreak = "syllable_break" // A syllable break; nothing was written
+	ActionJoiner        = "joiner"         // A ZWJ or ZWNJ, after a virama when needed
+	ActionToggle        = "toggle"         // The language toggle sequence
+	ActionPassThrough   = "pass_through"   // Input copied unchanged while toggled off
+	ActionUnmatched     = "unmatched"      // No mapping matched; the input was copied
+	ActionEndOfInput    = "end_of_input"   // A virama pending at the end of input
+)
+
+// Trace is the record of every decision made while mapping an input.
+type Trace struct {
+	Keymap  string      `json:"keymap"`
+	Version string      `json:"version"`        // Version of the keymap, empty if it has none
+	Base    string      `json:"base,omitempty"` // Versioned ID of the keymap it extends, if any
+	Input   string      `json:"input"`
+	Output  string      `json:"output"`
+	Steps   []TraceStep `json:"steps"`
+}
+
+// TraceStep records one step of the engine. Start and End delimit the input
+// span in runes; Index and Alternative are -1 when no mapping was used.
+type TraceStep struct {
+	Start        int         `json:"start"`
+	End          int         `json:"end"`
+	Input        string      `json:"input"`
+	Action       string      `json:"action"`
+	LHS          string      `json:"lhs,omitempty"`
+	Category     string      `json:"category,omitempty"`
+	Index        int         `json:"index"`
+	Alternative  int         `json:"alternative"`
+	RHS          string      `json:"rhs,omitempty"` // The chosen RHS, including any rule markup
+	Output       string      `json:"output"`        // The text this step wrote
+	Rules        []TraceRule `json:"rules,omitempty"`
+	Virama       bool        `json:"virama"`
+	ViramaReason string      `json:"viramaReason,omitempty"`
+}
+
+// TraceRule records a contextual rule attached to the chosen RHS.
+type TraceRule struct {
+	ChangePrevious     bool     `json:"changePrevious"`
+	Replace            int      `json:"replace,omitempty"`
+	Units              bool     `json:"units,omitempty"`
+	RequiredContext    string   `json:"requiredContext,omitempty"`
+	NewContext         string   `json:"newContext,omitempty"`
+	WhitespaceRequired bool     `json:"whitespaceRequired"`
+	Conditions         []string `json:"conditions,omitempty"` // Further conditions as written in the rule
+	Modification       string   `json:"modification,omitempty"`
+	Applied            bool     `json:"applied"`
+}
+
+// ExplainWithKeymap maps input with the given keymap and returns the trace of
+// every step along with the output.
+func (a *Aksharamala) ExplainWithKeymap(id, input string) (*Trace, error) {
+	session, err := a.NewSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return session.Explain(input)
+}
+
+// Explain maps input in the direction of the session's keymap and returns the
+// trace of every step along with the output.
+func (s *Session) Explain(input string) (*Trace, error) {
+	s.tracing = true
+	s.steps = nil
+	defer func() {
+		s.tracing = false
+		s.steps = nil
+	}()
+
+	output, err := s.Map(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Trace{Keymap: s.scheme.ID, Version: s.scheme.Version, Base: s.scheme.Base, Input: input, Output: output, Steps: s.steps}, nil
+}
+
+// beginStep starts recording a step at rune offset i, or returns nil when the
+// session is not tracing. All TraceStep methods accept a nil receiver.
+func (s *Session) beginStep(i int) *TraceStep {
+	if !s.tracing {
+		return nil
+	}
+	s.steps = append(s.steps, TraceStep{Start: i, Index: -1, Alternative: -1})
+	return &s.steps[len(s.steps)-1]
+}
+
+// endStep completes a step that consumed runes up to next and wrote output.
+func (s *Session) endStep(step *TraceStep, runes []rune, next int, output string) {
+	if step == nil {
+		return
+	}
+	step.End = next
+	step.Input = string(runes[step.Start:next])
+	step.Output = output
+}
+
+// traceEndOfInput records the virama written for a consonant at the end of input.
+func (s *Session) traceEndOfInput(end int) {
+	s.steps = append(s.steps, TraceStep{
+		Start:        end,
+		End:          end,
+		Action:       ActionEndOfInput,
+		Index:        -1,
+		Alternative:  -1,
+		Output:       s.viramaHandler.Virama,
+		Virama:       true,
+		ViramaReason: "consonant at the end of input in normal mode",
+	})
+}
+
+// action records what the step did.
+func (step *TraceStep) action(action string) {
+	if step != nil {
+		step.Action = action
+	}
+}
+
+// match records the mapping a step used.
+func (step *TraceStep) match(lhs string, rhs string, category string, index, alternative int) {
+	if step != nil {
+		step.Action = ActionMatch
+		step.LHS = lhs
+		step.RHS = rhs
+		step.Category = category
+		step.Index = index
+		step.Alternative = alternative
+	}
+}
+
+// word records the dictionary word a step wrote.
+func (step *TraceStep) word(lhs, rhs string) {
+	if step != nil {
+		step.Action = ActionWord
+		step.LHS = lhs
+		step.RHS = rhs
+	}
+}
+
+// virama records a virama decision and its reason.
+func (step *TraceStep) virama(inserted bool, reason string) {
+	if step != nil {
+		step.Virama = inserted
+		step.ViramaReason = reason
+	}
+}
+
+// rules records the contextual rules evaluated by a step.
+func (step *TraceStep) rules(rules []types.ContextualRule, applied []bool) {
+	if step == nil {
+		return
+	}
+	for i, rule := range rules {
+		var conditions []string
+		for _, condition := range rule.Conditions {
+			conditions = append(conditions, condition.String())
+		}
+		step.Rules = append(step.Rules, TraceRule{
+			ChangePrevious:     rule.ChangePrevious,
+			Replace:            rule.Replace,
+			Units:              rule.Units,
+			RequiredContext:    rule.RequiredContext,
+			NewContext:         rule.NewContext,
+			WhitespaceRequired: rule.WhitespaceRequired,
+			Conditions:         conditions,
+			Modification:       rule.Modification,
+			Applied:            applied[i],
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package translit
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"aks.go/internal/core"
+	"aks.go/internal/keymap"
+	"aks.go/internal/types"
+)
+
+// TestExplain verifies that the trace reports matches, categories and virama
+// decisions for each step, and that it survives a JSON round trip.
+func TestExplain(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	trace, err := aks.ExplainWithKeymap("hindi", "kSh")
+	if err != nil {
+		t.Fatalf("Failed to explain: %v", err)
+	}
+	if trace.Output != "क्ष" || len(trace.Steps) != 2 {
+		t.Fatalf("Unexpected trace: %+v", trace)
+	}
+
+	sh := trace.Steps[1]
+	if sh.LHS != "Sh" || sh.Start != 1 || sh.End != 3 || sh.Category != "consonants" || sh.Alternative != 0 {
+		t.Errorf("Unexpected step for 'Sh': %+v", sh)
+	}
+	if !sh.Virama || sh.ViramaReason == "" || sh.Output != "्ष" {
+		t.Errorf("Expected a virama before 'ष' with a reason, got %+v", sh)
+	}
+	scheme, _ := store.GetKeymap("hindi")
+	section := scheme.Categories[sh.Category]
+	if section.Mappings.All()[sh.Index].LHS[0] != "Sh" {
+		t.Errorf("Mapping index %d does not point at 'Sh'", sh.Index)
+	}
+
+	data, err := json.Marshal(trace)
+	if err != nil {
+		t.Fatalf("Failed to marshal trace: %v", err)
+	}
+	var decoded Trace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal trace: %v", err)
+	}
+	if !reflect.DeepEqual(*trace, decoded) {
+		t.Errorf("Trace changed in a JSON round trip:\n%+v\n%+v", *trace, decoded)
+	}
+
+	// Normal mode reports the matra choice and the virama at the end of input
+	trace, err = aks.ExplainWithKeymap("teluguRts", "kaak")
+	if err != nil {
+		t.Fatalf("Failed to explain: %v", err)
+	}
+	actions := []string{ActionMatch, ActionMatch, ActionMatch, ActionEndOfInput}
+	if len(trace.Steps) != len(actions) {
+		t.Fatalf("Expected %d steps, got %+v", len(actions), trace.Steps)
+	}
+	for i, action := range actions {
+		if trace.Steps[i].Action != action {
+			t.Errorf("Step %d: expected action %s, got %+v", i, action, trace.Steps[i])
+		}
+	}
+	if trace.Steps[1].Alternative != 1 || trace.Steps[1].Output != "ా" {
+		t.Errorf("Expected the matra alternative for 'aa', got %+v", trace.Steps[1])
+	}
+
+	// Reverse traces report whether the inherent vowel was written
+	trace, err = aks.ExplainWithKeymap("rhindi", "कमल")
+	if err != nil {
+		t.Fatalf("Failed to explain: %v", err)
+	}
+	if trace.Output != "kamal" || !trace.Steps[0].Virama || trace.Steps[2].Virama {
+		t.Errorf("Unexpected reverse trace: %+v", trace)
+	}
+}
+
+// TestExplainRules verifies that contextual rules are reported with the
+// condition that decided whether they applied.
+func TestExplainRules(t *testing.T) {
+	scheme := types.TransliterationScheme{
+		ID:       "rules",
+		Scheme:   types.SchemeITRANS,
+		Metadata: types.Metadata{Virama: "्, smart"},
+		Categories: map[string]types.Section{
+			"consonants": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"q"}, RHS: []string{"क(W)ं"}},
+				}),
+			},
+			"vowels": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"a"}, RHS: []string{"अ", "\u0000"}},
+				}),
+			},
+		},
+	}
+
+	session, err := newSession(types.CompileScheme(scheme))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	trace, err := session.Explain("qaq")
+	if err != nil {
+		t.Fatalf("Failed to explain: %v", err)
+	}
+	if trace.Output != "ककं" {
+		t.Errorf("Expected 'ककं', got %q", trace.Output)
+	}
+
+	first, last := trace.Steps[0], trace.Steps[2]
+	if len(first.Rules) != 1 || !first.Rules[0].WhitespaceRequired || first.Rules[0].Applied {
+		t.Errorf("Expected an unapplied whitespace rule inside the word, got %+v", first.Rules)
+	}
+	if len(last.Rules) != 1 || !last.Rules[0].Applied || last.Rules[0].Modification != "ं" {
+		t.Errorf("Expected the whitespace rule to apply at the end, got %+v", last.Rules)
+	}
+	if first.RHS != "क(W)ं" {
+		t.Errorf("Expected the chosen RHS with its rule markup, got %q", first.RHS)
+	}
+}
@@ -8,189 +8,274 @@ import (
 	"aks.go/internal/types"
 )
 
-// Transliterate performs transliteration for the input string using the active scheme.
+// Transliterate performs transliteration for the input string using the session's keymap.
 // Returns the transliterated string.
-func (a *Aksharamala) Transliterate(input string) (string, error) {
+func (s *Session) Transliterate(input string) (string, error) {
 	// Reset context for a clean state
-	a.context = types.NewContext()
-	a.context.Input = input
-	a.viramaHandler = types.NewViramaHandler(a.viramaHandler.Mode, a.viramaHandler.Virama, a.context)
-
-	var result strings.Builder
-	length := len(input)
-	for i := 0; i < length; {
-		a.context.Position = i
-		foundMatch := false
-
-		// Handle space character
-		if i < length && input[i] == ' ' {
-			shouldAddVirama, shouldAddSpace := a.viramaHandler.HandleSpace()
-			if shouldAddVirama {
-				result.WriteString(a.viramaHandler.Virama)
-			}
-			if shouldAddSpace {
-				result.WriteRune(' ')
-			}
-			i++
-			a.context.LatestLookup = core.LookupResult{Output: " ", Category: "other"}
-			continue
-		}
+	s.reset(input)
 
-		// Greedily match substrings to find the longest match
-		for j := length - i; j > 0; j-- {
-			if i+j <= length {
-				combination := input[i : i+j]
-				lookupResult := a.lookup(combination)
-
-				if lookupResult.Output != "" {
-					if lookupResult.Output == "\x00" && a.context.LatestLookup.Category == "consonants" {
-						a.context.LatestLookup = lookupResult
-						i += j // Move the index forward by the length of the match
-						foundMatch = true
-						break
-					}
+	var result types.Output
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		i = s.transliterateStep(runes, i, &result)
+	}
+	if s.finishTransliterate(&result) && s.tracing {
+		s.traceEndOfInput(len(runes))
+	}
 
-					// Parse and apply contextual rules
-					baseOutput, rules := types.ParseContextualRules(lookupResult.Output)
-					lookupResult.Output = baseOutput
+	return result.String(), nil
+}
 
-					// Only add virama for regular consonants, not for word boundary markers
-					if lookupResult.Category != "word_boundary" {
-						nextCategory := a.getCategoryForRHS(lookupResult.Output)
-						if a.viramaHandler.ShouldInsertVirama(lookupResult.Output, nextCategory) {
-							result.WriteString(a.viramaHandler.Virama)
-						}
-					}
+// transliterateStep processes the token starting at rune offset i of runes,
+// writes its output to result and returns the offset of the next token.
+func (s *Session) transliterateStep(runes []rune, i int, result *types.Output) int {
+	s.context.Position = i
+	s.context.Length = 0
+	s.mark = result.Len()
+	s.lead = 0
+	s.rewrite = s.mark
 
-					result.WriteString(lookupResult.Output)
+	step := s.beginStep(i)
+	next := s.transliterateToken(runes, i, result, step)
+	if step != nil {
+		s.endStep(step, runes, next, result.Since(s.mark))
+	}
+	if s.aligning {
+		s.alignStep(i, next, result)
+	}
+	return next
+}
 
-					// Apply any contextual rules
-					if err := a.context.ApplyContextualRules(rules, &result); err != nil {
-						// Log error but continue with transliteration
-						fmt.Printf("Error applying contextual rules: %v\n", err)
-					}
+// transliterateToken does the work of transliterateStep, recording its
+// decisions in step when tracing.
+func (s *Session) transliterateToken(runes []rune, i int, result *types.Output, step *TraceStep) int {
+	if s.context.PassThrough {
+		return s.passThroughStep(runes, i, result, step)
+	}
 
-					a.context.LatestLookup = lookupResult
-					i += j // Move the index forward by the length of the match
-					foundMatch = true
-					break // Exit the loop once a match is found
-				}
+	// Whole words of the dictionary take precedence over the mappings
+	if output, n, ok := s.compiled.WordAt(runes, i, s.context.Previous); ok {
+		s.context.Length = n
+		step.word(string(runes[i:i+n]), output)
+		insert, reason := s.viramaHandler.ViramaDecision(output, core.CategoryOther)
+		if insert {
+			s.writeLeadingVirama(result)
+		}
+		step.virama(insert, reason)
+		result.Write(output, core.CategoryOther)
+		s.context.LatestLookup = core.LookupResult{Output: output, Category: core.CategoryOther, Found: true, MatchLength: n}
+		return i + n
+	}
+
+	// Handle space character
+	if runes[i] == ' ' {
+		shouldAddVirama, shouldAddSpace := s.viramaHandler.HandleSpace()
+		if shouldAddVirama {
+			s.writeLeadingVirama(result)
+			step.virama(true, "space follows a consonant in normal mode")
+		}
+		if shouldAddSpace {
+			result.Write(" ", core.CategoryOther)
+		}
+		step.action(ActionSpace)
+		s.context.LatestLookup = core.LookupResult{Output: " ", Category: core.CategoryOther, MatchLength: 1}
+		return i + 1
+	}
+
+	// Walk the compiled trie; candidates come back longest first
+	for _, match := range s.compiled.MatchRunes(runes, i) {
+		lookupResult := s.resolve(match.Entries, match.Length)
+		if lookupResult.Output == "" {
+			continue
+		}
+		next := i + match.Length // Move the index forward by the length of the match
+		s.context.Length = match.Length
+		step.match(string(runes[i:next]), lookupResult.Output, lookupResult.Category, lookupResult.Index, lookupResult.Alternative)
+
+		switch lookupResult.Output {
+		case core.ToggleMarker:
+			// Settle a pending virama as at the end of input, then stop transliterating
+			if s.viramaHandler.HandleEndOfInput() {
+				s.writeLeadingVirama(result)
+				step.virama(true, "consonant before a switch to pass-through in normal mode")
+			}
+			step.action(ActionToggle)
+			s.context.PassThrough = true
+			s.context.LatestLookup = core.LookupResult{Category: core.CategoryOther, MatchLength: match.Length}
+			return next
+		case core.SyllableBreak:
+			// Nothing is written; a preceding consonant keeps its inherent vowel
+			if s.compiled.Role(lookupResult.Category) != types.RoleIndependentVowel {
+				step.action(ActionSyllableBreak)
+			}
+			s.context.LatestLookup = lookupResult
+			return next
+		case core.Nothing:
+			s.context.LatestLookup = lookupResult
+			return next
+		case core.ZWNJ, core.ZWJ:
+			if s.viramaHandler.HandleJoiner() {
+				s.writeLeadingVirama(result)
+				step.virama(true, "joiner follows a consonant")
 			}
+			step.action(ActionJoiner)
+			result.Write(lookupResult.Output, lookupResult.Category)
+			s.context.LatestLookup = lookupResult
+			return next
 		}
 
-		// If no match was found, process the current character
-		if !foundMatch {
-			char := string(input[i])
-			lookupResult := a.lookup(char)
-			if lookupResult.Output != "" {
-				// Parse and apply contextual rules
-				baseOutput, rules := types.ParseContextualRules(lookupResult.Output)
-				lookupResult.Output = baseOutput
-
-				// Only add virama for regular consonants, not for word boundary markers
-				if lookupResult.Category != "word_boundary" {
-					nextCategory := a.getCategoryForRHS(lookupResult.Output)
-					if a.viramaHandler.ShouldInsertVirama(lookupResult.Output, nextCategory) {
-						result.WriteString(a.viramaHandler.Virama)
-					}
-				}
+		// Parse and apply contextual rules
+		baseOutput, rules := types.ParseContextualRules(lookupResult.Output)
+		lookupResult.Output = baseOutput
 
-				result.WriteString(lookupResult.Output)
+		// Only add virama for regular consonants, not for word boundary markers
+		if lookupResult.Category != core.CategoryWordBoundary {
+			nextCategory := s.compiled.CategoryForRHS(lookupResult.Output)
+			insert, reason := s.viramaHandler.ViramaDecision(lookupResult.Output, nextCategory)
+			if insert {
+				s.writeLeadingVirama(result)
+			}
+			step.virama(insert, reason)
+		} else {
+			step.virama(false, "word boundary variant")
+		}
 
-				// Apply any contextual rules
-				if err := a.context.ApplyContextualRules(rules, &result); err != nil {
-					// Log error but continue with transliteration
-					fmt.Printf("Error applying contextual rules: %v\n", err)
-				}
+		result.Write(lookupResult.Output, lookupResult.Category)
 
-				a.context.LatestLookup = lookupResult
-			} else {
-				result.WriteString(char)
-				a.context.LatestLookup = core.LookupResult{Output: char, Category: "other"}
+		// Apply any contextual rules
+		var before string
+		if s.aligning {
+			before = result.String()
+		}
+		if step != nil && len(rules) > 0 {
+			applied, err := s.context.ApplyContextualRulesReport(rules, result)
+			if err != nil {
+				fmt.Printf("Error applying contextual rules: %v\n", err)
 			}
-			i++ // Move to the next character
+			step.rules(rules, applied)
+		} else if err := s.context.ApplyContextualRules(rules, result); err != nil {
+			// Log error but continue with transliteration
+			fmt.Printf("Error applying contextual rules: %v\n", err)
+		}
+		if s.aligning && len(rules) > 0 {
+			s.rewrite = rewrittenFrom(before, result.String())
 		}
+
+		s.context.LatestLookup = lookupResult
+		return next
 	}
 
-	// Handle end of input
-	if a.viramaHandler.HandleEndOfInput() {
-		result.WriteString(a.viramaHandler.Virama)
+	// If no match was found, copy the current character as is
+	char := string(runes[i])
+	step.action(ActionUnmatched)
+	s.recordUnmatched(i)
+	result.Write(s.compiled.Normalize(char), core.CategoryOther)
+	s.context.LatestLookup = core.LookupResult{Output: char, Category: core.CategoryOther, MatchLength: 1}
+	return i + 1
+}
+
+// passThroughStep copies the rune at offset i of runes to result unchanged,
+// or leaves pass-through mode if the keymap's toggle sequence starts there.
+// The toggle sequence itself is never written.
+func (s *Session) passThroughStep(runes []rune, i int, result *types.Output, step *TraceStep) int {
+	for _, match := range s.compiled.MatchRunes(runes, i) {
+		for _, entry := range match.Entries {
+			if len(entry.RHS) > 0 && entry.RHS[0] == core.ToggleMarker {
+				step.match(string(runes[i:i+match.Length]), entry.RHS[0], entry.Category, entry.Index, 0)
+				step.action(ActionToggle)
+				s.context.PassThrough = false
+				s.context.LatestLookup = core.LookupResult{Category: core.CategoryOther, MatchLength: match.Length}
+				return i + match.Length
+			}
+		}
 	}
 
-	return result.String(), nil
+	char := string(runes[i])
+	result.Write(char, core.CategoryOther)
+	step.action(ActionPassThrough)
+	s.context.LatestLookup = core.LookupResult{Output: char, Category: core.CategoryOther, MatchLength: 1}
+	return i + 1
 }
 
-// lookup finds the transliteration for the given string.
-// Returns the LookupResult for the character.
-func (a *Aksharamala) lookup(combination string) core.LookupResult {
-	for category, section := range a.activeScheme.Categories {
-		for _, mapping := range section.Mappings.All() {
-			for _, lhs := range mapping.LHS {
-				if lhs == combination {
-					rhs := mapping.RHS
-					if len(rhs) == 0 {
-						continue
-					}
+// writeLeadingVirama writes a virama that completes the previous token before
+// the current step writes its own output.
+func (s *Session) writeLeadingVirama(result *types.Output) {
+	result.Write(s.viramaHandler.Virama, "virama")
+	s.lead = len(s.viramaHandler.Virama)
+}
 
-					matchLen := len([]rune(combination))
-
-					// Check for word boundary variants first
-					if len(rhs) > 1 {
-						// Check if the second option has a word boundary condition
-						if strings.Contains(rhs[1], "(W)") {
-							if a.context.IsSeparator(matchLen) {
-								// Remove the (W) marker and return the rest
-								output := strings.Replace(rhs[1], "(W)", "", 1)
-								// Mark this as a special category so virama isn't added
-								return core.LookupResult{
-									Output:      output,
-									Category:    "word_boundary",
-									Found:       true,
-									MatchLength: matchLen,
-								}
-							}
-						} else if category == "vowels" && a.context.LatestLookup.Category == "consonants" {
-							// Use matra if the previous character is a consonant
-							return core.LookupResult{
-								Output:      rhs[1],
-								Category:    category,
-								Found:       true,
-								MatchLength: matchLen,
-							}
-						}
-					}
+// finishTransliterate resolves any virama still pending at the end of input
+// and reports whether one was written.
+func (s *Session) finishTransliterate(result *types.Output) bool {
+	if !s.viramaHandler.HandleEndOfInput() {
+		return false
+	}
+	result.Write(s.viramaHandler.Virama, "virama")
+	return true
+}
+
+// resolve picks the output for a set of mappings sharing the matched LHS.
+// The first mapping with a non-empty RHS wins; its alternative RHS is used for
+// word-boundary (W) variants and for matras following a consonant.
+func (s *Session) resolve(entries []core.TrieEntry, matchLen int) core.LookupResult {
+	for _, entry := range entries {
+		rhs := entry.RHS
+		if len(rhs) == 0 {
+			continue
+		}
 
-					// Use first option as default
+		// Check for word boundary variants first
+		if len(rhs) > 1 {
+			// Check if the second option has a word boundary condition
+			if strings.Contains(rhs[1], "(W)") {
+				if s.context.IsSeparator(matchLen) {
+					// Remove the (W) marker and return the rest
+					output := strings.Replace(rhs[1], "(W)", "", 1)
+					// Mark this as a special category so virama isn't added
 					return core.LookupResult{
-						Output:      rhs[0],
-						Category:    category,
+						Output:      output,
+						Category:    core.CategoryWordBoundary,
 						Found:       true,
 						MatchLength: matchLen,
+						Index:       entry.Index,
+						Alternative: 1,
 					}
 				}
+			} else if s.compiled.Role(entry.Category) == types.RoleIndependentVowel && s.compiled.Role(s.context.LatestLookup.Category) == types.RoleConsonant {
+				// Use matra if the previous character is a consonant
+				return core.LookupResult{
+					Output:      rhs[1],
+					Category:    entry.Category,
+					Found:       true,
+					MatchLength: matchLen,
+					Index:       entry.Index,
+					Alternative: 1,
+				}
 			}
 		}
+
+		// Use first option as default
+		return core.LookupResult{
+			Output:      rhs[0],
+			Category:    entry.Category,
+			Found:       true,
+			MatchLength: matchLen,
+			Index:       entry.Index,
+		}
 	}
 
 	// No match found
 	return core.LookupResult{
 		Output:      "",
-		Category:    "other",
+		Category:    core.CategoryOther,
 		Found:       false,
 		MatchLength: 0,
 	}
 }
 
-// getCategoryForRHS determines which category a character belongs to
-func (a *Aksharamala) getCategoryForRHS(output string) string {
-	for category, section := range a.activeScheme.Categories {
-		for _, mapping := range section.Mappings.All() {
-			for _, rhs := range mapping.RHS {
-				if rhs == output {
-					return category
-				}
-			}
-		}
-	}
-	return "other"
+// lookup finds the transliteration for an exact key.
+// Returns the LookupResult for the key.
+func (s *Session) lookup(key string) core.LookupResult {
+	entries, _ := s.compiled.Get(key)
+	return s.resolve(entries, len([]rune(key)))
 }
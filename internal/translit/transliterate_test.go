@@ -2,9 +2,15 @@ package translit
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"aks.go/internal/core"
 	"aks.go/internal/keymap"
+	"aks.go/internal/types"
 )
 
 // TestTransliterate tests the Transliterate method of the Aksharamala struct.
@@ -44,16 +50,22 @@ func TestTransliterate(t *testing.T) {
 		},
 	}
 
-	aks.SetActiveKeymap("hindi")
+	session, err := aks.NewSession("hindi")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
 
 	// Consolidate all the Hindi tests and run them
 	allTests := append(hindiSmallTests, hindiLargeTests...)
 	for _, test := range allTests {
-		translit(t, aks, test)
+		translit(t, session, test)
 	}
 
 	// Test with TeluguRTS keymap
-	aks.SetActiveKeymap("teluguRts")
+	session, err = aks.NewSession("teluguRts")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
 
 	teluguSmallTests := []struct {
 		input    string
@@ -87,7 +99,7 @@ func TestTransliterate(t *testing.T) {
 	// Consolidate all the Telugu RTS tests and run them
 	allTests = append(teluguSmallTests, teluguLargeTests...)
 	for _, test := range allTests {
-		translit(t, aks, test)
+		translit(t, session, test)
 	}
 }
 
@@ -98,7 +110,10 @@ func TestContextualMapping(t *testing.T) {
 	}
 
 	aks := NewAksharamala(store)
-	aks.SetActiveKeymap("teluguRts")
+	session, err := aks.NewSession("teluguRts")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
 
 	tests := []struct {
 		name     string
@@ -134,7 +149,7 @@ func TestContextualMapping(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result, _ := aks.Transliterate(test.input)
+			result, _ := session.Transliterate(test.input)
 			if result != test.expected {
 				t.Errorf("Test %s failed:\nexpected: %q\ngot: %q",
 					test.name, test.expected, result)
@@ -143,14 +158,433 @@ func TestContextualMapping(t *testing.T) {
 	}
 }
 
-func translit(t *testing.T, aks *Aksharamala, test struct {
+func translit(t *testing.T, session *Session, test struct {
 	input    string
 	expected string
 }) {
-	output, _ := aks.Transliterate(test.input)
+	output, _ := session.Transliterate(test.input)
 	if output != test.expected {
 		t.Errorf("For input '%s': expected '%s', got '%s'", test.input, test.expected, output)
 	} else {
 		t.Logf("For input '%s': output matches expected '%s'", test.input, test.expected)
 	}
 }
+
+// BenchmarkTransliterateLongInput measures forward transliteration over a
+// large input, where lookup cost dominates.
+func BenchmarkTransliterateLongInput(b *testing.B) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		b.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+	session, err := aks.NewSession("hindi")
+	if err != nil {
+		b.Fatalf("Failed to create session: %v", err)
+	}
+
+	input := strings.Repeat("yah ek su.ndar din hai. aaj ham bahut khush hai.n. ", 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := session.Transliterate(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestTransliterateNonASCII verifies that multi-byte characters in the source
+// pass through intact and count as single runes for separator lookahead.
+func TestTransliterateNonASCII(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id       string
+		input    string
+		expected string
+	}{
+		{"hindi", "“namaste”", "“नमस्ते”"},
+		{"hindi", "café", "च\u095Eé"},
+		{"teluguRts", "avasaram’", "అవసరం’"},
+		{"teluguRts", "jeevitam—", "జీవితం—"},
+	}
+
+	for _, test := range tests {
+		output, err := aks.TransliterateWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Error transliterating %q: %v", test.input, err)
+		}
+		if output != test.expected {
+			t.Errorf("For input %q: expected %q, got %q", test.input, test.expected, output)
+		}
+		if !utf8.ValidString(output) {
+			t.Errorf("For input %q: output is not valid UTF-8", test.input)
+		}
+	}
+}
+
+// TestLanguageToggle verifies that the toggle sequence declared in a keymap
+// switches to pass-through and back without appearing in the output.
+func TestLanguageToggle(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id       string
+		input    string
+		expected string
+	}{
+		{"teluguRts", "nEnu \\#Go code\\# raastaanu", "నేను Go code రాస్తాను"},
+		{"teluguRts", "k\\#x", "క్x"},      // Pending virama settles before the toggle
+		{"teluguRts", "\\#aa\\#aa", "aaఆ"}, // No matra right after a pass-through span
+		{"teluguRts", "\\#only english", "only english"},
+		{"hindi", "mai.n \\##Unicode\\## se pyaar", "मैं Unicode से प्यार"},
+		{"marathi", "\\##k\\##k", "kक"},
+	}
+
+	for _, test := range tests {
+		output, err := aks.TransliterateWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Error transliterating %q: %v", test.input, err)
+		}
+		if output != test.expected {
+			t.Errorf("For input %q: expected %q, got %q", test.input, test.expected, output)
+		}
+	}
+}
+
+// TestViramaBeforePunctuation pins the output of the shipped normal-mode
+// keymaps for a consonant followed by punctuation or a digit, which keeps its
+// inherent vowel: only a space, another consonant or the end of input takes
+// a virama.
+func TestViramaBeforePunctuation(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id       string
+		input    string
+		expected string
+	}{
+		{"teluguRts", "k.", "క."},
+		{"teluguRts", "kk.", "క్క."},
+		{"teluguRts", "k, ka", "క, క"},
+		{"teluguRts", "k?", "క?"},
+		{"teluguRts", "k1", "క1"},
+		{"teluguRts", "k", "క్"},
+		{"iast", "rāmam.", "रामम."},
+		{"iso15919", "rāmam, rāmam", "रामम, रामम्"},
+	}
+
+	for _, test := range tests {
+		output, err := aks.TransliterateWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Error transliterating %q: %v", test.input, err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: for input %q, expected %q, got %q", test.id, test.input, test.expected, output)
+		}
+	}
+}
+
+// TestConjunctControl verifies syllable breaks, ZWNJ and ZWJ in both directions.
+func TestConjunctControl(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id       string
+		input    string
+		expected string
+	}{
+		{"hindi", "ka_i", "कइ"},
+		{"hindi", "_a", "अ"},
+		{"hindi", "k{}Sh", "क्‌ष"},
+		{"hindi", "k{+}Sh", "क्‍ष"},
+		{"hindi", "a{}i", "अ‌इ"},
+		{"marathi", "dar{+}yaa", "दर्‍या"},
+		{"teluguRts", "a^i", "అఇ"},
+		{"teluguRts", "k^", "క"},
+		{"teluguRts", "k{}k", "క్‌క్"},
+		{"rhindi", "क्‌ष", "k.hSh"},
+		{"rhindi", "क्‍ष", "k{+}Sh"},
+		{"rhindi", "दर्‍या", "daRyaa"},
+		{"rhindi", "अ‌इ", "a{}i"},
+		{"rsanskrit", "क्‌ष", "kSha"}, // Unmapped joiners are dropped
+	}
+
+	for _, test := range tests {
+		output, err := aks.TransliterateWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Error transliterating %q: %v", test.input, err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: for input %q, expected %q, got %q", test.id, test.input, test.expected, output)
+		}
+		if strings.ContainsRune(output, 0) {
+			t.Errorf("%s: for input %q, output contains a NUL", test.id, test.input)
+		}
+	}
+}
+
+// TestContextualRuleConditions verifies lookbehind, lookahead and word-final
+// rule conditions evaluated during transliteration.
+func TestContextualRuleConditions(t *testing.T) {
+	scheme := types.TransliterationScheme{
+		ID:       "conditions",
+		Scheme:   types.SchemeITRANS,
+		Metadata: types.Metadata{Virama: "्, smart"},
+		Categories: map[string]types.Section{
+			"consonants": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"k"}, RHS: []string{"क"}},
+					{LHS: []string{"n"}, RHS: []string{"न(next:k c)ङ"}},
+					{LHS: []string{"m"}, RHS: []string{"म(final c)ं"}},
+				}),
+			},
+			"vowels": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"a"}, RHS: []string{"अ", "\u0000"}},
+				}),
+			},
+			"digits": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"1"}, RHS: []string{"१"}},
+					{LHS: []string{"5"}, RHS: []string{"५"}},
+				}),
+			},
+			"others": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"."}, RHS: []string{"।(prev:digits nextcat:digits c)."}},
+				}),
+			},
+		},
+	}
+
+	session, err := newSession(types.CompileScheme(scheme))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "१.५"},
+		{"1.", "१।"},
+		{"ka.", "क।"},
+		{"nka", "ङ्क"},
+		{"na", "न"},
+		{"kam", "कं"},
+		{"kam ka", "कं क"},
+		{"kamak", "कमक"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := session.Transliterate(tt.input)
+			if err != nil {
+				t.Fatalf("Transliteration failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestMigratedLegacyRules pins the output of the shipped keymaps whose legacy
+// rule markers were rewritten in the rule grammar: the "(t)ङ" alternatives of
+// Hindi now write the velar nasal from the first RHS, the reph is written
+// before a consonant only, and the "(c)(M)ం(x)" alternatives of TeluguRts,
+// which the engine never used, are gone.
+func TestMigratedLegacyRules(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id       string
+		input    string
+		expected string
+	}{
+		{"teluguRts", "nk", "న్క్"},
+		{"teluguRts", "mk", "మ్క్"},
+		{"teluguRts", "kk", "క్క్"},
+		{"teluguRts", "pankajam", "పన్కజం"},
+		{"teluguRts", "ganga", "గన్గ"},
+		{"teluguRts", "gaMga", "గంగ"},
+		{"teluguRts", "amma", "అమ్మ"},
+		{"hindi", "ganga", "गङ्ग"},
+		{"hindi", "anka", "अङ्क"},
+		{"hindi", "aNka", "अङ्क"},
+		{"hindi", "naam", "नाम"},
+		{"hindi", "^rya", "र्य"},
+		{"hindi", "a.c", ""},
+		{"hindi", "ka.c", "कॅ"},
+		{"marathi", "ganga", "गङ्ग"},
+	}
+
+	for _, test := range tests {
+		output, err := aks.TransliterateWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Error transliterating %q: %v", test.input, err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: for input %q, expected %q, got %q", test.id, test.input, test.expected, output)
+		}
+	}
+}
+
+// TestDictionaryWords verifies that the sample dictionary is loaded only on
+// request, that whole words of a keymap's dictionary take precedence over its
+// mappings in both directions, only at word boundaries, and that a later
+// dictionary overrides an earlier one.
+func TestDictionaryWords(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+	if output, _ := aks.TransliterateWithKeymap("hindi", "Delhi computer"); output != "डेल्हि चोम्पुतेर" {
+		t.Errorf("Expected the mappings without the sample dictionary, got %q", output)
+	}
+	if err := store.LoadDictionary("../../keymaps/dictionaries/Hindi.aksd"); err != nil {
+		t.Fatalf("Failed to load dictionary: %v", err)
+	}
+
+	tests := []struct {
+		id       string
+		input    string
+		expected string
+	}{
+		{"hindi", "Delhi", "दिल्ली"},
+		{"hindi", "DELHI delhi", "दिल्ली देल्हि"},
+		{"hindi", "delhI", "देल्ही"},
+		{"hindi", "(Delhi), Bombay.", "(दिल्ली), मुंबई।"},
+		{"hindi", "Delhiwala", "डेल्हिवल"},
+		{"hindi", "namaste Google", "नमस्ते गूगल"},
+		{"rhindi", "दिल्ली, मुंबई", "Delhi, Mumbai"},
+		{"rhindi", "नमस्ते", "namaste"},
+	}
+
+	for _, test := range tests {
+		output, err := aks.TransliterateWithKeymap(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Transliteration failed: %v", err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: for input %q, expected %q but got %q", test.id, test.input, test.expected, output)
+		}
+	}
+
+	// A user's dictionary overrides the shipped one
+	err := store.AddDictionary(types.Dictionary{
+		Keymap: "hindi",
+		Words:  []core.Mapping{{LHS: []string{"Delhi"}, RHS: []string{"देहली"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add dictionary: %v", err)
+	}
+	output, _ := aks.TransliterateWithKeymap("hindi", "Delhi Mumbai")
+	if output != "देहली मुंबई" {
+		t.Errorf("Expected the user's word to win, got %q", output)
+	}
+}
+
+// TestTransliterateVersioned verifies that a bare ID maps with the latest
+// version of a keymap, "id@version" with the one it names, and that results
+// report the version used.
+func TestTransliterateVersioned(t *testing.T) {
+	store := keymap.NewKeymapStore()
+	if err := store.LoadKeymaps("../../keymaps"); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	directory := t.TempDir()
+	older := `{
+		"version": "2024.1", "id": "hindi", "name": "Hindi", "language": "Hindi", "scheme": "ITRANS",
+		"metadata": {"virama": "्, smart"},
+		"categories": {"consonants": [{"lhs": ["k"], "rhs": ["क"]}]}
+	}`
+	if err := os.WriteFile(filepath.Join(directory, "Hindi-2024.aksj"), []byte(older), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.LoadKeymaps(directory); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+
+	aks := NewAksharamala(store)
+
+	tests := []struct {
+		id      string
+		input   string
+		output  string
+		version string
+	}{
+		{"hindi", "kam", "कम", "2025.1"},
+		{"hindi@2025.1", "kam", "कम", "2025.1"},
+		{"hindi@2024.1", "kam", "कam", "2024.1"},
+	}
+	for _, test := range tests {
+		result, err := aks.TransliterateVersioned(test.id, test.input)
+		if err != nil {
+			t.Fatalf("Transliteration failed: %v", err)
+		}
+		if result.Output != test.output || result.Keymap != "hindi" || result.Version != test.version {
+			t.Errorf("%s: expected %q from version %s, got %+v", test.id, test.output, test.version, result)
+		}
+		if output, _ := aks.TransliterateWithKeymap(test.id, test.input); output != test.output {
+			t.Errorf("%s: expected %q, got %q", test.id, test.output, output)
+		}
+	}
+
+	trace, err := aks.ExplainWithKeymap("hindi@2024.1", "k")
+	if err != nil || trace.Keymap != "hindi" || trace.Version != "2024.1" {
+		t.Errorf("Expected a trace of hindi 2024.1, got %+v, %v", trace, err)
+	}
+	if _, err := aks.TransliterateVersioned("hindi@2023.1", "k"); err == nil {
+		t.Error("Expected an error for a version that is not loaded")
+	}
+
+	// A later version of Hindi becomes the latest, and Marathi, which extends
+	// the bare ID, follows it
+	shipped, err := os.ReadFile("../../keymaps/Hindi.aksj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer := strings.Replace(strings.Replace(string(shipped), `"2025.1"`, `"2026.1"`, 1), `{"lhs":["k"],"rhs":["क"]}`, `{"lhs":["k"],"rhs":["ख"]}`, 1)
+	if err := os.WriteFile(filepath.Join(directory, "Hindi-2026.aksj"), []byte(newer), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.LoadKeymaps(directory); err != nil {
+		t.Fatalf("Failed to load keymaps: %v", err)
+	}
+	if result, _ := aks.TransliterateVersioned("hindi", "kam"); result.Output != "खम" || result.Version != "2026.1" {
+		t.Errorf("Expected the latest Hindi to be 2026.1, got %+v", result)
+	}
+	trace, err = aks.ExplainWithKeymap("marathi@2025.1", "kamala")
+	if err != nil || trace.Output != "खमल" || trace.Base != "hindi@2026.1" {
+		t.Errorf("Expected Marathi to follow Hindi 2026.1, got %+v, %v", trace, err)
+	}
+}
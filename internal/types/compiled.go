@@ -0,0 +1,134 @@
+package types
+
+import (
+	"aks.go/internal/core"
+	"aks.go/internal/norm"
+)
+
+// CompiledScheme is the immutable, lookup-ready form of a TransliterationScheme.
+// It is built once per keymap and shared by every transliteration that uses it,
+// so it must never be modified after CompileScheme returns.
+type CompiledScheme struct {
+	Scheme      TransliterationScheme
+	trie        *core.Trie
+	rhsCategory map[string]string
+	roles       map[string]Role
+	maxReplace  int
+	words       *Words
+}
+
+// CompileScheme builds the longest-match trie and the reverse RHS index for a scheme.
+// Categories are visited in CategoryNames order and mappings in file order, so the
+// first mapping declared for an LHS or RHS takes precedence. Each LHS is indexed in
+// its precomposed and decomposed forms, so input matches regardless of whether
+// diacritics or nuktas are typed as single characters or as combining marks.
+// Each RHS is stored in the normalization form the keymap asks for.
+func CompileScheme(scheme TransliterationScheme) *CompiledScheme {
+	compiled := &CompiledScheme{
+		Scheme:      scheme,
+		trie:        core.NewTrie(),
+		rhsCategory: make(map[string]string),
+		roles:       make(map[string]Role),
+		words:       NewWords(scheme.Words),
+	}
+
+	for _, category := range scheme.CategoryNames() {
+		compiled.roles[category] = scheme.RoleOf(category)
+		section := scheme.Categories[category]
+		for i, mapping := range section.Mappings.All() {
+			entry := core.TrieEntry{Category: category, Index: i, RHS: compiled.normalizeAll(mapping.RHS)}
+			for _, lhs := range mapping.LHS {
+				for _, variant := range norm.Variants(lhs) {
+					compiled.trie.Insert(variant, entry)
+				}
+			}
+			for _, rhs := range entry.RHS {
+				if _, exists := compiled.rhsCategory[rhs]; !exists {
+					compiled.rhsCategory[rhs] = category
+				}
+				_, rules := ParseContextualRules(rhs)
+				for _, rule := range rules {
+					if rule.ChangePrevious {
+						compiled.maxReplace = max(compiled.maxReplace, rule.Replace, 1)
+					}
+				}
+			}
+		}
+	}
+
+	return compiled
+}
+
+// MatchRunes returns all mappings whose LHS is a prefix of runes[start:], longest first.
+func (c *CompiledScheme) MatchRunes(runes []rune, start int) []core.TrieMatch {
+	return c.trie.MatchRunes(runes, start)
+}
+
+// Get returns the mappings whose LHS equals key exactly.
+func (c *CompiledScheme) Get(key string) ([]core.TrieEntry, bool) {
+	return c.trie.Get(key)
+}
+
+// CategoryAt returns the category of the longest mapping with an RHS that
+// matches runes at start, or "" if none matches.
+func (c *CompiledScheme) CategoryAt(runes []rune, start int) string {
+	if start < 0 || start >= len(runes) {
+		return ""
+	}
+	for _, match := range c.MatchRunes(runes, start) {
+		for _, entry := range match.Entries {
+			if len(entry.RHS) > 0 {
+				return entry.Category
+			}
+		}
+	}
+	return ""
+}
+
+// Role returns the role of a category, or "" for categories the scheme does
+// not have, such as core.CategoryOther for unmatched input. A nil CompiledScheme knows
+// only the roles of the conventionally named categories.
+func (c *CompiledScheme) Role(category string) Role {
+	if c == nil {
+		return defaultRoles[category]
+	}
+	return c.roles[category]
+}
+
+// Normalize returns s in the normalization form of the scheme's output.
+func (c *CompiledScheme) Normalize(s string) string {
+	return norm.Normalize(c.Scheme.Metadata.Normalization, s)
+}
+
+// normalizeAll returns the alternatives of an RHS in the normalization form of
+// the scheme's output, sharing rhs when the scheme has no form.
+func (c *CompiledScheme) normalizeAll(rhs []string) []string {
+	if c.Scheme.Metadata.Normalization == "" {
+		return rhs
+	}
+	normalized := make([]string, len(rhs))
+	for i, alternative := range rhs {
+		normalized[i] = c.Normalize(alternative)
+	}
+	return normalized
+}
+
+// MaxReplace returns the largest number of graphemes or units that any
+// contextual rule of the scheme replaces, or 0 if no rule replaces output.
+func (c *CompiledScheme) MaxReplace() int {
+	return c.maxReplace
+}
+
+// MaxLHSLength returns the length in runes of the longest LHS in the scheme.
+func (c *CompiledScheme) MaxLHSLength() int {
+	return c.trie.MaxLength()
+}
+
+// CategoryForRHS returns the category of the first mapping that lists rhs as
+// one of its alternatives, or core.CategoryOther if no mapping produces it.
+func (c *CompiledScheme) CategoryForRHS(rhs string) string {
+	if category, ok := c.rhsCategory[rhs]; ok {
+		return category
+	}
+	return core.CategoryOther
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"testing"
+
+	"aks.go/internal/core"
+)
+
+// TestCompileScheme verifies that a compiled scheme answers longest-match
+// and RHS category queries.
+func TestCompileScheme(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID: "test",
+		Categories: map[string]Section{
+			"consonants": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"k"}, RHS: []string{"क"}},
+					{LHS: []string{"kh", "K"}, RHS: []string{"ख"}},
+				}),
+			},
+			"vowels": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"a"}, RHS: []string{"अ", "\u0000"}},
+					{LHS: []string{"aa"}, RHS: []string{"आ", "ा"}},
+				}),
+			},
+		},
+	}
+
+	compiled := CompileScheme(scheme)
+
+	matches := compiled.MatchRunes([]rune("khaa"), 0)
+	if len(matches) != 2 || matches[0].Entries[0].RHS[0] != "ख" {
+		t.Fatalf("Expected 'kh' as the longest match, got %+v", matches)
+	}
+	if matches[0].Entries[0].Category != "consonants" || matches[0].Entries[0].Index != 1 {
+		t.Errorf("Unexpected entry metadata: %+v", matches[0].Entries[0])
+	}
+
+	if entries, found := compiled.Get("K"); !found || entries[0].RHS[0] != "ख" {
+		t.Errorf("Expected alternate LHS 'K' to resolve to 'ख', got %+v", entries)
+	}
+
+	if category := compiled.CategoryForRHS("ा"); category != "vowels" {
+		t.Errorf("Expected 'vowels' for matra, got %s", category)
+	}
+	if category := compiled.CategoryForRHS("x"); category != "other" {
+		t.Errorf("Expected 'other' for unknown RHS, got %s", category)
+	}
+	if compiled.MaxLHSLength() != 2 {
+		t.Errorf("Expected max LHS length 2, got %d", compiled.MaxLHSLength())
+	}
+}
+
+// TestCompileSchemeDecomposedInput verifies that precomposed and combining-mark
+// spellings of an LHS resolve to the same mapping.
+func TestCompileSchemeDecomposedInput(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID: "test",
+		Categories: map[string]Section{
+			"vowels": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"a"}, RHS: []string{"अ"}},
+					{LHS: []string{"ā"}, RHS: []string{"आ"}},
+				}),
+			},
+		},
+	}
+
+	compiled := CompileScheme(scheme)
+	for _, input := range []string{"ā", "a\u0304"} {
+		matches := compiled.MatchRunes([]rune(input), 0)
+		if len(matches) == 0 || matches[0].Entries[0].RHS[0] != "आ" {
+			t.Errorf("Expected %q to match 'ā', got %+v", input, matches)
+		}
+	}
+}
+
+// TestCompileSchemeNormalization verifies that the RHS alternatives are stored
+// in the scheme's normalization form.
+func TestCompileSchemeNormalization(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID:       "test",
+		Metadata: Metadata{Normalization: "nfd"},
+		Categories: map[string]Section{
+			"consonants": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"q"}, RHS: []string{"\u0958", "\u0929(W)"}},
+				}),
+			},
+		},
+	}
+
+	compiled := CompileScheme(scheme)
+	entries, _ := compiled.Get("q")
+	if entries[0].RHS[0] != "\u0915\u093C" || entries[0].RHS[1] != "\u0928\u093C(W)" {
+		t.Errorf("Expected decomposed RHS alternatives, got %q", entries[0].RHS)
+	}
+	if category := compiled.CategoryForRHS("\u0915\u093C"); category != "consonants" {
+		t.Errorf("Expected the normalized RHS to be indexed, got %s", category)
+	}
+	section := scheme.Categories["consonants"]
+	if section.Mappings.All()[0].RHS[0] != "\u0958" {
+		t.Errorf("Compiling must not modify the scheme's mappings")
+	}
+}
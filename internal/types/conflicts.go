@@ -0,0 +1,72 @@
+package types
+
+import (
+	"fmt"
+
+	"aks.go/internal/core"
+)
+
+// validateDuplicates rejects a category that maps the same LHS twice: only
+// the first mapping could ever match.
+func (s *TransliterationScheme) validateDuplicates() error {
+	for _, category := range s.CategoryNames() {
+		section := s.Categories[category]
+		seen := make(map[string]bool)
+		for _, mapping := range section.Mappings.All() {
+			for _, lhs := range mapping.LHS {
+				if seen[lhs] {
+					return fmt.Errorf("category '%s' in keymap '%s' maps LHS %q more than once", category, s.ID, lhs)
+				}
+				seen[lhs] = true
+			}
+		}
+	}
+	return nil
+}
+
+// conflicts describes the LHS strings mapped by more than one category, where
+// the later categories are never used, and, for keymaps that produce native
+// script, the RHS strings produced by categories of different roles, where the
+// engine takes the output to belong to the first category.
+func (s *TransliterationScheme) conflicts() []string {
+	var warnings []string
+	lhsCategory := make(map[string]string)
+	rhsCategory := make(map[string]string)
+	for _, category := range s.CategoryNames() {
+		section := s.Categories[category]
+		for _, mapping := range section.Mappings.All() {
+			for _, lhs := range mapping.LHS {
+				if first, exists := lhsCategory[lhs]; !exists {
+					lhsCategory[lhs] = category
+				} else if first != category {
+					warnings = append(warnings, fmt.Sprintf("category '%s' in keymap '%s': LHS %q is shadowed by category '%s', which comes first", category, s.ID, lhs, first))
+				}
+			}
+			if s.Scheme == SchemeUnicode {
+				continue
+			}
+			for _, rhs := range mapping.RHS {
+				base, _ := ParseContextualRules(rhs)
+				if IsControlOutput(base) {
+					continue
+				}
+				if first, exists := rhsCategory[base]; !exists {
+					rhsCategory[base] = category
+				} else if s.RoleOf(first) != s.RoleOf(category) {
+					warnings = append(warnings, fmt.Sprintf("category '%s' in keymap '%s': RHS %q is also produced by category '%s' and is treated as '%s'", category, s.ID, base, first, first))
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// IsControlOutput reports whether rhs is a control output, including
+// core.Nothing, rather than text.
+func IsControlOutput(rhs string) bool {
+	switch rhs {
+	case "", core.SyllableBreak, core.ZWNJ, core.ZWJ, core.ToggleMarker, core.Nothing:
+		return true
+	}
+	return false
+}
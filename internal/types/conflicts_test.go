@@ -0,0 +1,95 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"aks.go/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryOrder(t *testing.T) {
+	data := `{
+		"id": "order", "name": "Order", "language": "Hindi", "scheme": "ITRANS",
+		"categories": {
+			"vowels": [{"lhs": ["a"], "rhs": ["अ"]}],
+			"consonants": [{"lhs": ["k"], "rhs": ["क"]}],
+			"others": [{"lhs": ["M"], "rhs": ["ं"]}]
+		}
+	}`
+
+	var scheme TransliterationScheme
+	if err := json.Unmarshal([]byte(data), &scheme); err != nil {
+		t.Fatalf("Failed to unmarshal scheme: %v", err)
+	}
+	assert.Equal(t, []string{"vowels", "consonants", "others"}, scheme.CategoryNames(), "categories keep the order of the file")
+
+	scheme.Categories["digits"] = Section{}
+	scheme.Categories["accents"] = Section{}
+	assert.Equal(t, []string{"vowels", "consonants", "others", "accents", "digits"}, scheme.CategoryNames(), "unlisted categories follow in sorted order")
+
+	compact, err := ToCompactTransliterationScheme(scheme)
+	assert.NoError(t, err)
+	assert.Equal(t, scheme.CategoryNames(), compact.CategoryNames())
+}
+
+func TestValidateDuplicates(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID:       "duplicates",
+		Name:     "Duplicates",
+		Language: "Hindi",
+		Scheme:   SchemeITRANS,
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"kh", "k"}, RHS: []string{"ख"}},
+			})},
+		},
+	}
+
+	err := scheme.Validate()
+	if assert.Error(t, err) {
+		assert.Equal(t, `category 'consonants' in keymap 'duplicates' maps LHS "k" more than once`, err.Error())
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID:       "conflicts",
+		Name:     "Conflicts",
+		Language: "Hindi",
+		Scheme:   SchemeITRANS,
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"^"}, RHS: []string{core.SyllableBreak}},
+			})},
+			"vowels": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"अ"}},
+				{LHS: []string{"a"}, RHS: []string{"क"}},
+			})},
+			"others": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"_"}, RHS: []string{core.SyllableBreak}},
+				{LHS: []string{"kk"}, RHS: []string{"क"}},
+			})},
+		},
+		Order: []string{"consonants", "vowels", "others"},
+	}
+
+	if err := scheme.Validate(); err != nil {
+		t.Fatalf("Expected a valid scheme, got %v", err)
+	}
+	assert.Equal(t, []string{
+		`category 'vowels' in keymap 'conflicts': LHS "k" is shadowed by category 'consonants', which comes first`,
+		`category 'vowels' in keymap 'conflicts': RHS "क" is also produced by category 'consonants' and is treated as 'consonants'`,
+		`category 'others' in keymap 'conflicts': RHS "क" is also produced by category 'consonants' and is treated as 'consonants'`,
+	}, scheme.Warnings())
+
+	// Reversing the order reverses the precedence
+	scheme.Order = []string{"others", "vowels", "consonants"}
+	assert.Equal(t, []string{
+		`category 'vowels' in keymap 'conflicts': RHS "क" is also produced by category 'others' and is treated as 'others'`,
+		`category 'consonants' in keymap 'conflicts': LHS "k" is shadowed by category 'vowels', which comes first`,
+		`category 'consonants' in keymap 'conflicts': RHS "क" is also produced by category 'others' and is treated as 'others'`,
+	}, scheme.Warnings())
+}
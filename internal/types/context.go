@@ -1,7 +1,6 @@
 package types
 
 import (
-	"strings"
 	"unicode"
 
 	"aks.go/internal/core"
@@ -13,7 +12,14 @@ type Context struct {
 	LatestLookup   core.LookupResult // Tracks the result of the last lookup
 	CurrentContext string            // The current context marker (e.g., "M", "x")
 	Input          string            // The full input string being processed
-	Position       int               // Current position in the input string
+	Previous       rune              // The rune before Input when it continues earlier input, as a window of a stream, or 0
+	Position       int               // Current position in the input, in runes
+	Length         int               // Length in runes of the token at Position, once matched
+	PassThrough    bool              // Whether input is copied unchanged until the next toggle
+	Compiled       *CompiledScheme   // Keymap used to classify lookahead input, if any
+
+	runes      []rune // Cached rune form of Input
+	runesInput string // The Input value the cache was built from
 }
 
 // NewContext creates a new Context instance with default values.
@@ -30,23 +36,33 @@ func NewContext() *Context {
 func (ctx *Context) Reset() {
 	ctx.LatestLookup = core.LookupResult{}
 	ctx.CurrentContext = ""
-	ctx.Input = ""
+	ctx.PassThrough = false
+	ctx.SetInput("")
+	ctx.Previous = 0
 	ctx.Position = 0
+	ctx.Length = 0
 }
 
-// ContextualRule represents a rule for modifying output based on context.
-type ContextualRule struct {
-	ChangePrevious     bool   // (c) flag
-	RequiredContext    string // (M) - required context for rule to apply
-	NewContext         string // (m) - context to set after applying rule
-	WhitespaceRequired bool   // (W) flag - requires next char to be whitespace or EOS
-	Modification       string // The actual modification to apply
+// SetInput replaces the input being processed and caches its rune form.
+func (ctx *Context) SetInput(input string) {
+	ctx.Input = input
+	ctx.runes = []rune(input)
+	ctx.runesInput = input
+}
+
+// inputRunes returns the input as runes, rebuilding the cache if Input was
+// assigned directly instead of through SetInput.
+func (ctx *Context) inputRunes() []rune {
+	if ctx.runesInput != ctx.Input || (ctx.runes == nil && ctx.Input != "") {
+		ctx.SetInput(ctx.Input)
+	}
+	return ctx.runes
 }
 
 // IsSeparator checks if we're at a word boundary position in the input
 func (ctx *Context) IsSeparator(optMatchLen ...int) bool {
 	// Get all runes from the input
-	runes := []rune(ctx.Input)
+	runes := ctx.inputRunes()
 
 	// Determine which match length to use
 
@@ -76,7 +92,7 @@ func (ctx *Context) IsSeparator(optMatchLen ...int) bool {
 	nextRune := runes[nextPos]
 
 	// Check if it's a separator (whitespace, punctuation, or non-letter/non-mark)
-	return unicode.IsSpace(nextRune) || unicode.IsPunct(nextRune) || !(unicode.IsLetter(nextRune) || unicode.IsMark(nextRune))
+	return IsSeparatorRune(nextRune)
 }
 
 // ShouldApplyRule determines if a contextual rule should be applied based on all conditions
@@ -87,106 +103,93 @@ func (ctx *Context) ShouldApplyRule(rule ContextualRule) bool {
 	}
 
 	// Check whitespace requirement
-	if rule.WhitespaceRequired && !ctx.IsSeparator() {
+	if rule.WhitespaceRequired && !ctx.IsSeparator(ctx.Length) {
 		return false
 	}
 
-	return true
-}
-
-// parseRule extracts a single contextual rule from a rule string
-func parseRule(ruleStr string) (ContextualRule, bool) {
-	var rule ContextualRule
-	if len(ruleStr) < 1 {
-		return rule, false
+	for _, condition := range rule.Conditions {
+		if ctx.holds(condition) == condition.Negated {
+			return false
+		}
 	}
 
-	switch ruleStr[0] {
-	case 'W':
-		rule.WhitespaceRequired = true
-		rule.Modification = ruleStr[1:]
-	case 'c':
-		rule.ChangePrevious = true
-		rule.Modification = ruleStr[1:]
-	case 'M':
-		rule.RequiredContext = ruleStr
-	case 'x':
-		rule.NewContext = ruleStr
-	default:
-		return rule, false
-	}
-	return rule, true
+	return true
 }
 
-// ParseContextualRules parses the RHS string to extract contextual rules.
-// Returns the base output and any contextual rules found.
-func ParseContextualRules(rhs string) (string, []ContextualRule) {
-	var rules []ContextualRule
-
-	// Find the first rule marker
-	firstRule := strings.Index(rhs, "(")
-	if firstRule == -1 {
-		return rhs, nil // No rules found
-	}
-
-	// Extract base output (text before first rule)
-	baseOutput := rhs[:firstRule]
+// holds evaluates a condition, ignoring its negation, for the token at Position.
+func (ctx *Context) holds(condition Condition) bool {
+	runes := ctx.inputRunes()
+	next := ctx.Position + ctx.Length
 
-	// Split remaining string into segments
-	segments := strings.Split(rhs[firstRule:], ")")
-
-	for i, segment := range segments {
-		if len(segment) == 0 {
-			continue
+	switch condition.Kind {
+	case WordInitial:
+		if ctx.Position > len(runes) {
+			return true
 		}
-
-		// Extract rule content from parentheses
-		ruleStart := strings.Index(segment, "(")
-		if ruleStart == -1 {
-			// This is a modification for the previous rule
-			if len(rules) > 0 {
-				rules[len(rules)-1].Modification = segment
-			}
-			continue
+		previous := ctx.Previous
+		if ctx.Position > 0 {
+			previous = runes[ctx.Position-1]
 		}
-
-		ruleContent := segment[ruleStart+1:]
-		if rule, valid := parseRule(ruleContent); valid {
-			// Check for modification text before next rule
-			if i < len(segments)-1 {
-				nextRuleStart := strings.Index(segments[i+1], "(")
-				if nextRuleStart > 0 {
-					rule.Modification = segments[i+1][:nextRuleStart]
-				}
-			}
-			rules = append(rules, rule)
+		return !(unicode.IsLetter(previous) || unicode.IsMark(previous))
+	case WordFinal:
+		return ctx.IsSeparator(ctx.Length)
+	case PreviousCategory:
+		return ctx.LatestLookup.Category == condition.Value
+	case NextKey:
+		key := []rune(condition.Value)
+		if next < 0 || next+len(key) > len(runes) {
+			return false
 		}
+		return string(runes[next:next+len(key)]) == condition.Value
+	case NextCategory:
+		return ctx.Compiled != nil && ctx.Compiled.CategoryAt(runes, next) == condition.Value
+	case InContext:
+		return ctx.CurrentContext == condition.Value
 	}
-
-	return baseOutput, rules
+	return false
 }
 
 // ApplyContextualRules applies the contextual rules to modify the output.
-// Returns the modified output and any error encountered.
-func (ctx *Context) ApplyContextualRules(rules []ContextualRule, builder *strings.Builder) error {
-	output := builder.String()
-	modified := false
+// Text written by a rule takes the category of the output's last unit, which
+// is the base output of the mapping that carries the rules.
+// Returns any error encountered.
+func (ctx *Context) ApplyContextualRules(rules []ContextualRule, output *Output) error {
+	return ctx.applyContextualRules(rules, output, nil)
+}
 
-	for _, rule := range rules {
+// ApplyContextualRulesReport applies the rules like ApplyContextualRules and
+// reports, for each rule in order, whether it was applied.
+func (ctx *Context) ApplyContextualRulesReport(rules []ContextualRule, output *Output) ([]bool, error) {
+	applied := make([]bool, len(rules))
+	err := ctx.applyContextualRules(rules, output, applied)
+	return applied, err
+}
+
+// applyContextualRules applies rules to output, recording applied rules in
+// applied when it is not nil.
+func (ctx *Context) applyContextualRules(rules []ContextualRule, output *Output, applied []bool) error {
+	category := output.LastCategory()
+
+	for i, rule := range rules {
 		// Check if rule should be applied
 		if !ctx.ShouldApplyRule(rule) {
 			continue
 		}
+		if applied != nil {
+			applied[i] = true
+		}
 
 		if rule.ChangePrevious {
-			// Modify the previous character
-			if len(output) > 0 {
-				output = output[:len(output)-1] + rule.Modification
-				modified = true
+			// Replace the previous units or graphemes
+			if output.Len() > 0 {
+				if rule.Units {
+					output.ReplaceUnits(max(rule.Replace, 1), rule.Modification, category)
+				} else {
+					output.ReplaceGraphemes(max(rule.Replace, 1), rule.Modification, category)
+				}
 			}
-		} else if rule.Modification != "" {
-			output += rule.Modification
-			modified = true
+		} else {
+			output.Write(rule.Modification, category)
 		}
 
 		// Update context if specified
@@ -195,11 +198,5 @@ func (ctx *Context) ApplyContextualRules(rules []ContextualRule, builder *string
 		}
 	}
 
-	if modified {
-		// Clear the builder and write the modified output
-		builder.Reset()
-		builder.WriteString(output)
-	}
-
 	return nil
 }
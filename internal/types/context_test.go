@@ -1,7 +1,6 @@
 package types
 
 import (
-	"strings"
 	"testing"
 
 	"aks.go/internal/core"
@@ -180,12 +179,89 @@ func TestApplyContextualRulesWithWhitespace(t *testing.T) {
 			ctx.Input = tt.input
 			ctx.Position = tt.position
 
-			var builder strings.Builder
-			builder.WriteString(tt.initialOutput)
+			var output Output
+			output.Write(tt.initialOutput, "consonants")
 
-			err := ctx.ApplyContextualRules(tt.rules, &builder)
+			err := ctx.ApplyContextualRules(tt.rules, &output)
 			assert.NoError(t, err)
-			assert.Equal(t, tt.expectedOutput, builder.String())
+			assert.Equal(t, tt.expectedOutput, output.String())
+		})
+	}
+}
+
+func TestShouldApplyRuleConditions(t *testing.T) {
+	compiled := CompileScheme(TransliterationScheme{
+		ID: "conditions",
+		Categories: map[string]Section{
+			"digits": {Mappings: core.NewMappings([]core.Mapping{{LHS: []string{"5"}, RHS: []string{"५"}}})},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		position int
+		length   int
+		previous string
+		rule     string
+		expected bool
+	}{
+		{"Word-initial at the start", "ab", 0, 1, "", "(initial)", true},
+		{"Word-initial after a space", "x ab", 2, 1, "other", "(initial)", true},
+		{"Word-initial inside a word", "xab", 1, 1, "vowels", "(initial)", false},
+		{"Negated word-initial", "xab", 1, 1, "vowels", "(!initial)", true},
+		{"Word-final uses the token length", "abc d", 1, 2, "vowels", "(final)", true},
+		{"Word-final inside a word", "abc d", 1, 1, "vowels", "(final)", false},
+		{"Previous category", "1.5", 1, 1, "digits", "(prev:digits)", true},
+		{"Previous category differs", "a.5", 1, 1, "vowels", "(prev:digits)", false},
+		{"Next key", "nka", 0, 1, "", "(next:ka)", true},
+		{"Next key past the end", "nk", 0, 1, "", "(next:ka)", false},
+		{"Next category", "1.5", 1, 1, "digits", "(nextcat:digits)", true},
+		{"Next category at the end", "1.", 1, 1, "digits", "(nextcat:digits)", false},
+		{"All conditions must hold", "1.", 1, 1, "digits", "(prev:digits nextcat:digits)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext()
+			ctx.Compiled = compiled
+			ctx.SetInput(tt.input)
+			ctx.Position = tt.position
+			ctx.Length = tt.length
+			ctx.LatestLookup = core.LookupResult{Category: tt.previous}
+
+			_, rules, err := ParseRules(tt.rule)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ctx.ShouldApplyRule(rules[0]))
+		})
+	}
+}
+
+func TestApplyContextualRulesReplacesGraphemes(t *testing.T) {
+	tests := []struct {
+		name           string
+		rule           string
+		initialOutput  string
+		expectedOutput string
+	}{
+		{"One grapheme with its virama", "(c)ं", "कन्", "कं"},
+		{"Two graphemes", "(c2)ऽ", "कन्क", "कऽ"},
+		{"More graphemes than written", "(c5)ॐ", "कि", "ॐ"},
+		{"Joiners stay with their grapheme", "(c)र", "कर्‍", "कर"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext()
+			ctx.SetInput("x")
+
+			var output Output
+			output.Write(tt.initialOutput, "consonants")
+
+			_, rules, err := ParseRules(tt.rule)
+			assert.NoError(t, err)
+			assert.NoError(t, ctx.ApplyContextualRules(rules, &output))
+			assert.Equal(t, tt.expectedOutput, output.String())
 		})
 	}
 }
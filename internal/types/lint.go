@@ -0,0 +1,281 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"aks.go/internal/norm"
+)
+
+// Severity tells how serious a lint diagnostic is.
+type Severity string
+
+// Severities of lint diagnostics. An error stops the keymap from loading or
+// working; a warning marks a mapping that does not do what it appears to do.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Checks that report lint diagnostics.
+const (
+	CheckDecode        = "decode"         // The file is not valid JSON for its format
+	CheckValidation    = "validation"     // The keymap is rejected for a reason no other check covers
+	CheckField         = "field"          // A mandatory field is missing or unknown
+	CheckExtends       = "extends"        // An overlay cannot be applied to its base
+	CheckVirama        = "virama"         // The virama metadata does not parse
+	CheckRoles         = "roles"          // A category role is unknown or missing
+	CheckEmptyCategory = "empty_category" // A category has no mappings
+	CheckMapping       = "mapping"        // A mapping has no LHS or no RHS
+	CheckShadowed      = "shadowed"       // An LHS is matched by an earlier mapping
+	CheckUnreachable   = "unreachable"    // An LHS can never be matched
+	CheckRule          = "rule"           // An RHS has a malformed contextual rule
+	CheckCoverage      = "coverage"       // A reverse keymap misses code points of its script
+)
+
+// Diagnostic is a single problem found by Lint.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	Category string   `json:"category,omitempty"`
+	Index    int      `json:"index"` // Index of the mapping within Category, or -1
+	Message  string   `json:"message"`
+}
+
+// String returns the diagnostic with its location, as printed by the lint command.
+func (d Diagnostic) String() string {
+	switch {
+	case d.Category == "":
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	case d.Index < 0:
+		return fmt.Sprintf("%s: category '%s': %s", d.Severity, d.Category, d.Message)
+	}
+	return fmt.Sprintf("%s: category '%s' mapping %d: %s", d.Severity, d.Category, d.Index, d.Message)
+}
+
+// scriptBlocks are the Unicode blocks of the Indic scripts, by script name.
+var scriptBlocks = []struct {
+	name        string
+	first, last rune
+}{
+	{"Devanagari", 0x0900, 0x097F},
+	{"Bengali", 0x0980, 0x09FF},
+	{"Gurmukhi", 0x0A00, 0x0A7F},
+	{"Gujarati", 0x0A80, 0x0AFF},
+	{"Oriya", 0x0B00, 0x0B7F},
+	{"Tamil", 0x0B80, 0x0BFF},
+	{"Telugu", 0x0C00, 0x0C7F},
+	{"Kannada", 0x0C80, 0x0CFF},
+	{"Malayalam", 0x0D00, 0x0D7F},
+}
+
+// Lint analyzes a scheme that need not be valid and returns its problems in
+// file order: missing fields and metadata first, then the categories and their
+// mappings in precedence order, then the script coverage of reverse keymaps.
+// Unlike Validate it does not stop at the first problem and does not modify s.
+func (s *TransliterationScheme) Lint() []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(severity Severity, check, category string, index int, format string, args ...any) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: severity,
+			Check:    check,
+			Category: category,
+			Index:    index,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"id", s.ID}, {"name", s.Name}, {"language", s.Language}, {"scheme", s.Scheme},
+	} {
+		if field.value == "" {
+			report(SeverityError, CheckField, "", -1, "mandatory field %q is missing", field.name)
+		}
+	}
+	switch s.Scheme {
+	case "", SchemeUnicode, SchemeITRANS, SchemeRTS, SchemeIAST, SchemeISO15919:
+	default:
+		report(SeverityError, CheckField, "", -1, "scheme %q is not supported", s.Scheme)
+	}
+	if _, _, err := ParseVirama(s.Metadata.Virama); err != nil {
+		report(SeverityError, CheckVirama, "", -1, "virama metadata %q is rejected: %v; write it as \"<virama>, <normal|smart>\"", s.Metadata.Virama, err)
+	}
+	if len(s.Categories) == 0 {
+		report(SeverityError, CheckEmptyCategory, "", -1, "the keymap has no categories")
+	}
+	if err := s.validateRoles(); err != nil {
+		report(SeverityError, CheckRoles, "", -1, "%v", err)
+	}
+
+	compiled := CompileScheme(*s)
+	reverse := s.Scheme == SchemeUnicode
+	for _, category := range s.CategoryNames() {
+		section := s.Categories[category]
+		mappings := section.Mappings.All()
+		if len(mappings) == 0 {
+			report(SeverityWarning, CheckEmptyCategory, category, -1, "the category has no mappings; remove it or add some")
+		}
+		for i, mapping := range mappings {
+			if len(mapping.LHS) == 0 {
+				report(SeverityError, CheckMapping, category, i, "the mapping has no LHS")
+			}
+			if len(mapping.RHS) == 0 {
+				report(SeverityError, CheckMapping, category, i, "the mapping has no RHS")
+			}
+			for _, lhs := range mapping.LHS {
+				if severity, check, message, ok := compiled.unreachable(category, i, lhs, reverse); ok {
+					report(severity, check, category, i, "%s", message)
+				}
+			}
+			for j, rhs := range mapping.RHS {
+				if compiled.unusedAlternative(category, j, rhs) {
+					report(SeverityWarning, CheckUnreachable, category, i, "RHS %q is never written: the engine only uses the second RHS for a (W) variant or for a vowel after a consonant; move its rules into the first RHS", rhs)
+				}
+				_, rules, err := ParseRules(rhs)
+				if err != nil {
+					report(SeverityError, CheckRule, category, i, "RHS %q has an invalid rule: %v", rhs, err)
+					continue
+				}
+				for _, legacy := range LegacyRules(rhs) {
+					report(SeverityWarning, CheckRule, category, i, "RHS %q: %s; rewrite it with the rule grammar of docs/contextual_rules.md", rhs, legacy)
+				}
+				for _, rule := range rules {
+					for _, condition := range rule.Conditions {
+						if condition.Kind != PreviousCategory && condition.Kind != NextCategory {
+							continue
+						}
+						if _, exists := s.Categories[condition.Value]; !exists {
+							report(SeverityWarning, CheckRule, category, i, "RHS %q tests unknown category '%s' and never applies as intended", rhs, condition.Value)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if reverse {
+		if block, missing := s.missingCodePoints(); len(missing) > 0 {
+			report(SeverityWarning, CheckCoverage, "", -1, "no LHS reads %d code points of the %s block, which are copied as is: %s", len(missing), block, strings.Join(missing, ", "))
+		}
+	}
+	return diagnostics
+}
+
+// unusedAlternative reports whether the engine never writes rhs, the RHS
+// at index of a mapping of category. Only the first RHS is written, except for
+// a second RHS that holds a (W) variant or is the matra of an independent
+// vowel.
+func (c *CompiledScheme) unusedAlternative(category string, index int, rhs string) bool {
+	switch {
+	case index == 0:
+		return false
+	case index > 1:
+		return true
+	}
+	return !strings.Contains(rhs, "(W)") && c.Role(category) != RoleIndependentVowel
+}
+
+// unreachable reports why the LHS lhs of mapping index of category never
+// matches, as the severity, the check that found it and a message, or false
+// if it matches whenever it starts a token. Only an LHS mapped twice in one
+// category is an error, since Validate rejects it.
+func (c *CompiledScheme) unreachable(category string, index int, lhs string, reverse bool) (Severity, string, string, bool) {
+	if lhs == "" {
+		return SeverityWarning, CheckUnreachable, "an empty LHS never matches", true
+	}
+	if !reverse && strings.HasPrefix(lhs, " ") {
+		return SeverityWarning, CheckUnreachable, fmt.Sprintf("LHS %q starts with a space, which the engine writes before matching, so it never matches", lhs), true
+	}
+
+	// An input of exactly lhs is the longest match at its start; the first
+	// mapping with an RHS among those sharing it wins
+	entries, _ := c.trie.Get(lhs)
+	for _, entry := range entries {
+		self := entry.Category == category && entry.Index == index
+		if len(entry.RHS) == 0 {
+			if self {
+				return "", "", "", false // Reported as a mapping without an RHS
+			}
+			continue
+		}
+		if !self {
+			section := c.Scheme.Categories[entry.Category]
+			winner := section.Mappings.All()[entry.Index]
+			switch {
+			case entry.Category == category && contains(winner.LHS, lhs):
+				return SeverityError, CheckShadowed, fmt.Sprintf("LHS %q is already mapped in this category by mapping %d, so this one never matches it", lhs, entry.Index), true
+			case contains(winner.LHS, lhs):
+				return SeverityWarning, CheckShadowed, fmt.Sprintf("LHS %q is shadowed by mapping %d of category '%s', which comes first", lhs, entry.Index, entry.Category), true
+			}
+			return SeverityWarning, CheckShadowed, fmt.Sprintf("LHS %q is equivalent to an LHS of mapping %d of category '%s', which comes first and matches the same input", lhs, entry.Index, entry.Category), true
+		}
+		if reverse || entry.RHS[0] != "" {
+			return "", "", "", false
+		}
+
+		// The engine skips a match that writes nothing and tries shorter ones
+		runes := []rune(lhs)
+		for _, match := range c.trie.MatchRunes(runes, 0) {
+			if match.Length < len(runes) {
+				prefix := string(runes[:match.Length])
+				return SeverityWarning, CheckUnreachable, fmt.Sprintf("LHS %q writes nothing, so the engine matches its prefix %q instead", lhs, prefix), true
+			}
+		}
+		return SeverityWarning, CheckUnreachable, fmt.Sprintf("LHS %q writes nothing, so the engine copies its input as is", lhs), true
+	}
+	return "", "", "", false
+}
+
+// missingCodePoints returns the name of the script block most LHS strings of a
+// reverse keymap are written in and the assigned code points of that block
+// that appear in no LHS, in either normalization form.
+func (s *TransliterationScheme) missingCodePoints() (string, []string) {
+	covered := make(map[rune]bool)
+	for _, section := range s.Categories {
+		for _, mapping := range section.Mappings.All() {
+			for _, lhs := range mapping.LHS {
+				for _, variant := range norm.Variants(lhs) {
+					for _, r := range variant {
+						covered[r] = true
+					}
+				}
+			}
+		}
+	}
+
+	counts := make([]int, len(scriptBlocks))
+	best := -1
+	for r := range covered {
+		for i, block := range scriptBlocks {
+			if r >= block.first && r <= block.last {
+				counts[i]++
+				if best < 0 || counts[i] > counts[best] {
+					best = i
+				}
+			}
+		}
+	}
+	if best < 0 {
+		return "", nil
+	}
+
+	block := scriptBlocks[best]
+	var missing []string
+	for r := block.first; r <= block.last; r++ {
+		if !covered[r] && unicode.IsGraphic(r) {
+			missing = append(missing, fmt.Sprintf("U+%04X %s", r, string(r)))
+		}
+	}
+	return block.name, missing
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"aks.go/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID:       "lint",
+		Language: "Hindi",
+		Scheme:   SchemeITRANS,
+		Metadata: Metadata{Virama: "0x094D"},
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"kh", "k"}, RHS: []string{"ख"}},
+				{LHS: []string{" t"}, RHS: []string{"त"}},
+				{LHS: []string{"n"}, RHS: []string{"न", "(t)ङ"}},
+				{LHS: []string{"m"}, RHS: []string{"म", "(W)ं", "ं"}},
+			})},
+			"vowels": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"अ"}},
+				{LHS: []string{"aa"}, RHS: []string{""}},
+				{LHS: []string{"a"}, RHS: []string{"अ", "(prev:consonant)ा"}},
+				{LHS: []string{"i"}, RHS: []string{"इ(W"}},
+			})},
+			"digits": {},
+		},
+		Order: []string{"consonants", "vowels", "digits"},
+	}
+
+	var got []string
+	for _, d := range scheme.Lint() {
+		got = append(got, d.Check+" "+d.String())
+	}
+	assert.Equal(t, []string{
+		`field error: mandatory field "name" is missing`,
+		`virama error: virama metadata "0x094D" is rejected: invalid or missing virama metadata: 0x094D; write it as "<virama>, <normal|smart>"`,
+		`shadowed error: category 'consonants' mapping 1: LHS "k" is already mapped in this category by mapping 0, so this one never matches it`,
+		`unreachable warning: category 'consonants' mapping 2: LHS " t" starts with a space, which the engine writes before matching, so it never matches`,
+		`unreachable warning: category 'consonants' mapping 3: RHS "(t)ङ" is never written: the engine only uses the second RHS for a (W) variant or for a vowel after a consonant; move its rules into the first RHS`,
+		`rule warning: category 'consonants' mapping 3: RHS "(t)ङ": legacy marker '(t)' at offset 0 is ignored with the text after it; rewrite it with the rule grammar of docs/contextual_rules.md`,
+		`unreachable warning: category 'consonants' mapping 4: RHS "ं" is never written: the engine only uses the second RHS for a (W) variant or for a vowel after a consonant; move its rules into the first RHS`,
+		`shadowed warning: category 'vowels' mapping 0: LHS "k" is shadowed by mapping 0 of category 'consonants', which comes first`,
+		`unreachable warning: category 'vowels' mapping 1: LHS "aa" writes nothing, so the engine matches its prefix "a" instead`,
+		`rule warning: category 'vowels' mapping 2: RHS "(prev:consonant)ा" tests unknown category 'consonant' and never applies as intended`,
+		`rule error: category 'vowels' mapping 3: RHS "इ(W" has an invalid rule: unclosed '(' at offset 3`,
+		`empty_category warning: category 'digits': the category has no mappings; remove it or add some`,
+	}, got)
+	assert.Empty(t, scheme.Name, "Lint does not fill in defaults")
+}
+
+func TestLintEquivalentLHS(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID: "equivalent", Name: "Equivalent", Language: "Hindi", Scheme: SchemeUnicode,
+		Metadata: Metadata{Virama: "a, smart"},
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"क़"}, RHS: []string{"q"}},
+				{LHS: []string{"क़"}, RHS: []string{"k."}},
+			})},
+		},
+	}
+
+	diagnostics := scheme.Lint()
+	if assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, CheckShadowed, diagnostics[0].Check)
+		assert.Equal(t, 1, diagnostics[0].Index)
+		assert.Contains(t, diagnostics[0].Message, "is equivalent to an LHS of mapping 0")
+	}
+}
+
+func TestLintCoverage(t *testing.T) {
+	var mappings []core.Mapping
+	for r := rune(0x0C00); r <= 0x0C7F; r++ {
+		if r != 0x0C15 && r != 0x0C16 {
+			mappings = append(mappings, core.Mapping{LHS: []string{string(r)}, RHS: []string{"x"}})
+		}
+	}
+	scheme := TransliterationScheme{
+		ID: "rtelugu", Name: "Telugu", Language: "Telugu", Scheme: SchemeUnicode,
+		Metadata:   Metadata{Virama: "a, smart"},
+		Categories: map[string]Section{"others": {Mappings: core.NewMappings(mappings)}},
+	}
+
+	var coverage []Diagnostic
+	for _, d := range scheme.Lint() {
+		if d.Check == CheckCoverage {
+			coverage = append(coverage, d)
+		}
+	}
+	if assert.Len(t, coverage, 1) {
+		assert.True(t, strings.HasPrefix(coverage[0].Message, "no LHS reads 2 code points of the Telugu block"), coverage[0].Message)
+		assert.Contains(t, coverage[0].Message, "U+0C15 క, U+0C16 ఖ")
+	}
+}
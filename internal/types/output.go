@@ -0,0 +1,192 @@
+package types
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Unit is a piece of output written at once by the engine, such as the output
+// of a mapping or an inserted virama, tagged with its category.
+type Unit struct {
+	Text     string
+	Category string
+}
+
+// Output is the text produced by a transliteration run, kept as the sequence
+// of units written so far. Units are written whole and only ever removed at
+// rune boundaries, so the text is always valid UTF-8. Replacements truncate
+// the text in place, so their cost does not grow with the length of the output.
+type Output struct {
+	text      []byte
+	units     []unitEnd
+	graphemes []int // Byte offsets at which the graphemes of the text start
+}
+
+// unitEnd records where a unit ends in the text and its category.
+type unitEnd struct {
+	end      int
+	category string
+}
+
+// Write appends text to the output as a unit of the given category. Invalid
+// UTF-8 in text is replaced with U+FFFD; empty text writes nothing.
+func (o *Output) Write(text, category string) {
+	if text == "" {
+		return
+	}
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, "\uFFFD")
+	}
+	for i, r := range text {
+		if len(o.text)+i == 0 || !isGraphemeExtender(r) {
+			o.graphemes = append(o.graphemes, len(o.text)+i)
+		}
+	}
+	o.text = append(o.text, text...)
+	o.units = append(o.units, unitEnd{end: len(o.text), category: category})
+}
+
+// String returns the text of the output.
+func (o *Output) String() string {
+	return string(o.text)
+}
+
+// Since returns the text of the output from offset on.
+func (o *Output) Since(offset int) string {
+	if offset >= len(o.text) {
+		return ""
+	}
+	return string(o.text[offset:])
+}
+
+// Len returns the length of the output in bytes.
+func (o *Output) Len() int {
+	return len(o.text)
+}
+
+// Units returns the units of the output in order.
+func (o *Output) Units() []Unit {
+	units := make([]Unit, len(o.units))
+	start := 0
+	for i, unit := range o.units {
+		units[i] = Unit{Text: string(o.text[start:unit.end]), Category: unit.category}
+		start = unit.end
+	}
+	return units
+}
+
+// LastCategory returns the category of the last unit, or "" if there is none.
+func (o *Output) LastCategory() string {
+	if len(o.units) == 0 {
+		return ""
+	}
+	return o.units[len(o.units)-1].category
+}
+
+// Reset empties the output.
+func (o *Output) Reset() {
+	o.text = o.text[:0]
+	o.units = o.units[:0]
+	o.graphemes = o.graphemes[:0]
+}
+
+// UnitStart returns the byte offset at which the last n units start.
+func (o *Output) UnitStart(n int) int {
+	if n <= 0 {
+		return len(o.text)
+	}
+	if n >= len(o.units) {
+		return 0
+	}
+	return o.units[len(o.units)-n-1].end
+}
+
+// ReplaceUnits replaces the last n units with text as a unit of the given category.
+func (o *Output) ReplaceUnits(n int, text, category string) {
+	o.truncate(o.UnitStart(n))
+	o.Write(text, category)
+}
+
+// ReplaceGraphemes replaces the last n graphemes with text as a unit of the
+// given category. A grapheme is taken to be a base character with the
+// combining marks and joiners that follow it, so a consonant with its virama
+// or matra is replaced as a whole even when they were written as separate units.
+func (o *Output) ReplaceGraphemes(n int, text, category string) {
+	o.truncate(o.graphemesStart(n))
+	o.Write(text, category)
+}
+
+// graphemesStart returns the byte offset at which the last n graphemes start.
+func (o *Output) graphemesStart(n int) int {
+	if n <= 0 {
+		return len(o.text)
+	}
+	if n >= len(o.graphemes) {
+		return 0
+	}
+	return o.graphemes[len(o.graphemes)-n]
+}
+
+// Cut removes the text before offset from the output and returns it. Units
+// that straddle offset keep their remaining text.
+func (o *Output) Cut(offset int) string {
+	offset = min(offset, len(o.text))
+	cut := string(o.text[:offset])
+	kept := o.units[:0]
+	for _, unit := range o.units {
+		if unit.end > offset {
+			kept = append(kept, unitEnd{end: unit.end - offset, category: unit.category})
+		}
+	}
+	o.units = kept
+
+	// The text left may start in the middle of a grapheme, whose marks then
+	// make a grapheme of their own
+	starts := make([]int, 0, len(o.graphemes)+1)
+	if offset < len(o.text) {
+		starts = append(starts, 0)
+	}
+	for _, start := range o.graphemes {
+		if start > offset {
+			starts = append(starts, start-offset)
+		}
+	}
+	o.graphemes = starts
+	o.text = o.text[:copy(o.text, o.text[offset:])]
+	return cut
+}
+
+// truncate drops the text from offset on. A unit cut in the middle keeps its
+// text before offset.
+func (o *Output) truncate(offset int) {
+	if offset >= len(o.text) {
+		return
+	}
+
+	i := len(o.units)
+	for i > 0 && o.units[i-1].end > offset {
+		i--
+	}
+	start := 0
+	if i > 0 {
+		start = o.units[i-1].end
+	}
+	if i < len(o.units) && start < offset {
+		o.units[i].end = offset
+		i++
+	}
+	o.units = o.units[:i]
+
+	j := len(o.graphemes)
+	for j > 0 && o.graphemes[j-1] >= offset {
+		j--
+	}
+	o.graphemes = o.graphemes[:j]
+	o.text = o.text[:offset]
+}
+
+// isGraphemeExtender reports whether r continues the grapheme before it.
+func isGraphemeExtender(r rune) bool {
+	return unicode.IsMark(r) || r == '\u200C' || r == '\u200D'
+}
@@ -0,0 +1,131 @@
+package types
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputUnits(t *testing.T) {
+	var output Output
+	output.Write("क", "consonants")
+	output.Write("्", "virama")
+	output.Write("ष", "consonants")
+	output.Write("", "other")
+	output.Write("ि", "vowels")
+
+	assert.Equal(t, "क्षि", output.String())
+	assert.Equal(t, []Unit{
+		{Text: "क", Category: "consonants"},
+		{Text: "्", Category: "virama"},
+		{Text: "ष", Category: "consonants"},
+		{Text: "ि", Category: "vowels"},
+	}, output.Units())
+	assert.Equal(t, "vowels", output.LastCategory())
+	assert.Equal(t, len("क्"), output.UnitStart(2))
+	assert.Equal(t, 0, output.UnitStart(10))
+	assert.Equal(t, output.Len(), output.UnitStart(0))
+}
+
+func TestOutputReplace(t *testing.T) {
+	tests := []struct {
+		name     string
+		replace  func(*Output)
+		expected []Unit
+	}{
+		{
+			name:    "Units",
+			replace: func(o *Output) { o.ReplaceUnits(2, "ं", "consonants") },
+			expected: []Unit{
+				{Text: "क", Category: "consonants"},
+				{Text: "्", Category: "virama"},
+				{Text: "ं", Category: "consonants"},
+			},
+		},
+		{
+			name:    "A grapheme spanning units",
+			replace: func(o *Output) { o.ReplaceGraphemes(1, "ं", "consonants") },
+			expected: []Unit{
+				{Text: "क", Category: "consonants"},
+				{Text: "्", Category: "virama"},
+				{Text: "ं", Category: "consonants"},
+			},
+		},
+		{
+			name:    "A grapheme inside a unit",
+			replace: func(o *Output) { o.ReplaceGraphemes(2, "ॐ", "others") },
+			expected: []Unit{
+				{Text: "ॐ", Category: "others"},
+			},
+		},
+		{
+			name:     "More than written",
+			replace:  func(o *Output) { o.ReplaceUnits(9, "", "others") },
+			expected: []Unit{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output Output
+			output.Write("क", "consonants")
+			output.Write("्", "virama")
+			output.Write("ष", "consonants")
+			output.Write("ि", "vowels")
+
+			tt.replace(&output)
+			assert.Equal(t, tt.expected, output.Units())
+			assert.True(t, utf8.ValidString(output.String()))
+		})
+	}
+
+	// A grapheme spread over all units empties the output; a unit cut in the
+	// middle keeps its text before the cut
+	var output Output
+	output.Write("कि", "consonants")
+	output.Write("ं", "others")
+	output.ReplaceGraphemes(1, "", "others")
+	assert.Empty(t, output.Units())
+
+	output.Write("क्ष", "consonants")
+	output.ReplaceGraphemes(1, "त्र", "consonants")
+	assert.Equal(t, []Unit{
+		{Text: "क्", Category: "consonants"},
+		{Text: "त्र", Category: "consonants"},
+	}, output.Units())
+}
+
+func TestOutputCut(t *testing.T) {
+	var output Output
+	output.Write("क", "consonants")
+	output.Write("्", "virama")
+	output.Write("ष", "consonants")
+
+	assert.Equal(t, "क", output.Cut(output.UnitStart(2)))
+	assert.Equal(t, []Unit{{Text: "्", Category: "virama"}, {Text: "ष", Category: "consonants"}}, output.Units())
+	output.ReplaceGraphemes(1, "ख", "consonants")
+	assert.Equal(t, []Unit{{Text: "्", Category: "virama"}, {Text: "ख", Category: "consonants"}}, output.Units())
+	assert.Equal(t, "्ख", output.Cut(output.Len()))
+	assert.Equal(t, 0, output.Len())
+}
+
+// BenchmarkOutputReplaceGraphemes measures a rule replacing the last
+// grapheme after every unit of a long output, which must not copy the output.
+func BenchmarkOutputReplaceGraphemes(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var output Output
+		for j := 0; j < 20000; j++ {
+			output.Write("क", "consonants")
+			output.Write("्", "virama")
+			output.ReplaceGraphemes(1, "ङ्", "consonants")
+		}
+	}
+}
+
+func TestOutputInvalidUTF8(t *testing.T) {
+	var output Output
+	output.Write("क\xe0\xa4", "consonants")
+	assert.True(t, utf8.ValidString(output.String()))
+	assert.Equal(t, "क�", output.String())
+}
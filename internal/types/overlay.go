@@ -0,0 +1,213 @@
+package types
+
+import (
+	"fmt"
+
+	"aks.go/internal/core"
+)
+
+// Removal lists what an overlay keymap drops from its base keymap.
+type Removal struct {
+	LHS        []string `json:"lhs,omitempty"`        // LHS strings no longer mapped
+	Categories []string `json:"categories,omitempty"` // Categories dropped with all their mappings
+}
+
+// IsOverlay reports whether the scheme extends a base keymap and must be
+// flattened before it can be validated or compiled.
+func (s *TransliterationScheme) IsOverlay() bool {
+	return s.Extends != ""
+}
+
+// Flatten applies the overlay s to its base keymap and returns the complete
+// scheme, which no longer extends anything. Overrides are defined per LHS:
+//
+//   - Categories named in "remove" are dropped, then LHS strings named in
+//     "remove" are dropped from every mapping of the base.
+//   - Each mapping of the overlay takes over its LHS strings from every mapping
+//     before it, in any category. It takes the place of the first mapping of
+//     its own category that loses an LHS to it, or is added at the end of its
+//     category.
+//   - Mappings left without an LHS are dropped.
+//   - Categories of the overlay that the base lacks follow the base categories.
+//   - Words of the overlay take over their spellings from the words of the base.
+//   - Fields and metadata the overlay sets replace those of the base, except
+//     roles, which are merged by category, and the version, which is always
+//     the overlay's own. Base records the versioned ID of the base.
+//
+// Flatten returns an error when the overlay removes an LHS or a category the
+// base does not have, since that is almost always a typo.
+func (s *TransliterationScheme) Flatten(base TransliterationScheme) (TransliterationScheme, error) {
+	flat := TransliterationScheme{
+		Comments:   s.Comments,
+		Version:    s.Version,
+		ID:         s.ID,
+		Base:       base.VersionedID(),
+		Name:       firstNonEmpty(s.Name, base.Name),
+		License:    firstNonEmpty(s.License, base.License),
+		Language:   firstNonEmpty(s.Language, base.Language),
+		Scheme:     firstNonEmpty(s.Scheme, base.Scheme),
+		Metadata:   mergeMetadata(base.Metadata, s.Metadata),
+		Categories: make(map[string]Section),
+	}
+	if flat.Comments == nil {
+		flat.Comments = base.Comments
+	}
+
+	// Copy the base, without the removed categories
+	removed := make(map[string]bool)
+	if s.Remove != nil {
+		for _, category := range s.Remove.Categories {
+			if _, exists := base.Categories[category]; !exists {
+				return TransliterationScheme{}, fmt.Errorf("keymap '%s' removes category '%s', which '%s' does not have", s.ID, category, base.ID)
+			}
+			removed[category] = true
+			delete(flat.Metadata.Roles, category)
+		}
+	}
+	mappings := make(map[string][]core.Mapping)
+	for _, category := range base.CategoryNames() {
+		if removed[category] {
+			continue
+		}
+		section := base.Categories[category]
+		for _, mapping := range section.Mappings.All() {
+			mapping.LHS = append([]string(nil), mapping.LHS...)
+			mappings[category] = append(mappings[category], mapping)
+		}
+		flat.Categories[category] = Section{Comments: section.Comments}
+		flat.Order = append(flat.Order, category)
+	}
+
+	// Drop the removed LHS strings
+	if s.Remove != nil {
+		for _, lhs := range s.Remove.LHS {
+			if _, found := dropLHS(mappings, []string{lhs}, ""); !found {
+				return TransliterationScheme{}, fmt.Errorf("keymap '%s' removes LHS %q, which '%s' does not map", s.ID, lhs, base.ID)
+			}
+		}
+	}
+
+	// Apply the overlay's mappings in order
+	for _, category := range s.CategoryNames() {
+		overlay := s.Categories[category]
+		if _, exists := flat.Categories[category]; !exists {
+			flat.Categories[category] = Section{Comments: overlay.Comments}
+			flat.Order = append(flat.Order, category)
+		}
+		for _, mapping := range overlay.Mappings.All() {
+			mapping.LHS = append([]string(nil), mapping.LHS...)
+			entries := mappings[category]
+			if position, _ := dropLHS(mappings, mapping.LHS, category); position >= 0 {
+				entries = append(entries[:position], append([]core.Mapping{mapping}, entries[position:]...)...)
+			} else {
+				entries = append(entries, mapping)
+			}
+			mappings[category] = entries
+		}
+	}
+
+	// Apply the overlay's words
+	overridden := flatLHS(s.Words)
+	for _, word := range base.Words {
+		var kept []string
+		for _, lhs := range word.LHS {
+			if !contains(overridden, lhs) {
+				kept = append(kept, lhs)
+			}
+		}
+		if len(kept) > 0 {
+			word.LHS = kept
+			flat.Words = append(flat.Words, word)
+		}
+	}
+	flat.Words = append(flat.Words, s.Words...)
+
+	// Keep the mappings that still have an LHS
+	for category, section := range flat.Categories {
+		var kept []core.Mapping
+		for _, mapping := range mappings[category] {
+			if len(mapping.LHS) > 0 {
+				kept = append(kept, mapping)
+			}
+		}
+		section.Mappings = core.NewMappings(kept)
+		flat.Categories[category] = section
+	}
+
+	return flat, nil
+}
+
+// dropLHS removes the strings of lhs from every mapping and reports whether
+// any mapping had one, together with the index in category of the first
+// mapping of that category that had one, or -1.
+func dropLHS(mappings map[string][]core.Mapping, lhs []string, category string) (int, bool) {
+	position, found := -1, false
+	for name, entries := range mappings {
+		for i := range entries {
+			kept := entries[i].LHS[:0]
+			for _, existing := range entries[i].LHS {
+				if !contains(lhs, existing) {
+					kept = append(kept, existing)
+				}
+			}
+			if len(kept) == len(entries[i].LHS) {
+				continue
+			}
+			entries[i].LHS = kept
+			found = true
+			if name == category && position < 0 {
+				position = i
+			}
+		}
+	}
+	return position, found
+}
+
+// flatLHS returns the LHS strings of all mappings.
+func flatLHS(mappings []core.Mapping) []string {
+	var lhs []string
+	for _, mapping := range mappings {
+		lhs = append(lhs, mapping.LHS...)
+	}
+	return lhs
+}
+
+// mergeMetadata returns the base metadata with the fields the overlay sets
+// replaced, and the roles of both, those of the overlay taking precedence.
+func mergeMetadata(base, overlay Metadata) Metadata {
+	merged := base
+	merged.Virama = firstNonEmpty(overlay.Virama, base.Virama)
+	merged.FontName = firstNonEmpty(overlay.FontName, base.FontName)
+	merged.IconEnabled = firstNonEmpty(overlay.IconEnabled, base.IconEnabled)
+	merged.IconDisabled = firstNonEmpty(overlay.IconDisabled, base.IconDisabled)
+	merged.Schwa = firstNonEmpty(overlay.Schwa, base.Schwa)
+	merged.Normalization = firstNonEmpty(overlay.Normalization, base.Normalization)
+	merged.Romanization = firstNonEmpty(overlay.Romanization, base.Romanization)
+	if overlay.FontSize != 0 {
+		merged.FontSize = overlay.FontSize
+	}
+	if overlay.SchwaExceptions != nil {
+		merged.SchwaExceptions = overlay.SchwaExceptions
+	}
+
+	merged.Roles = nil
+	for _, roles := range []map[string]Role{base.Roles, overlay.Roles} {
+		for category, role := range roles {
+			if merged.Roles == nil {
+				merged.Roles = make(map[string]Role)
+			}
+			merged.Roles[category] = role
+		}
+	}
+	return merged
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package types
+
+import (
+	"testing"
+
+	"aks.go/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	base := TransliterationScheme{
+		Version: "2025.1", ID: "base", Name: "Base", Language: "Devanagari", Scheme: SchemeITRANS,
+		Metadata: Metadata{Virama: "्, smart", Roles: map[string]Role{"vedic": RoleOther}},
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"k"}, RHS: []string{"क"}},
+				{LHS: []string{"ch", "c"}, RHS: []string{"च"}},
+				{LHS: []string{"Y"}, RHS: []string{"य़"}},
+			})},
+			"others": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"R"}, RHS: []string{"र्"}},
+			})},
+			"vedic": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"\\`"}, RHS: []string{"॑"}},
+			})},
+		},
+		Order: []string{"consonants", "others", "vedic"},
+	}
+	overlay := TransliterationScheme{
+		ID: "overlay", Name: "Overlay", Extends: "base",
+		Remove:   &Removal{LHS: []string{"Y"}, Categories: []string{"vedic"}},
+		Metadata: Metadata{Roles: map[string]Role{"punctuation": RoleOther}},
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"c"}, RHS: []string{"छ"}},
+				{LHS: []string{"kt"}, RHS: []string{"क्त"}},
+				{LHS: []string{"R"}, RHS: []string{"ऱ"}},
+			})},
+			"punctuation": {Mappings: core.NewMappings([]core.Mapping{
+				{LHS: []string{"."}, RHS: []string{"।"}},
+			})},
+		},
+		Order: []string{"consonants", "punctuation"},
+	}
+
+	flat, err := overlay.Flatten(base)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, flat.IsOverlay())
+	assert.Equal(t, "overlay", flat.ID)
+	assert.Equal(t, "Overlay", flat.Name)
+	assert.Equal(t, "Devanagari", flat.Language, "unset fields come from the base")
+	assert.Empty(t, flat.Version, "an overlay is versioned on its own")
+	assert.Equal(t, "base@2025.1", flat.Base)
+	assert.Equal(t, "्, smart", flat.Metadata.Virama)
+	assert.Equal(t, map[string]Role{"punctuation": RoleOther}, flat.Metadata.Roles, "roles of removed categories are dropped")
+	assert.Equal(t, []string{"consonants", "others", "punctuation"}, flat.CategoryNames())
+	consonants, others := flat.Categories["consonants"], flat.Categories["others"]
+	assert.Equal(t, []core.Mapping{
+		{LHS: []string{"k"}, RHS: []string{"क"}},
+		{LHS: []string{"c"}, RHS: []string{"छ"}},
+		{LHS: []string{"ch"}, RHS: []string{"च"}},
+		{LHS: []string{"kt"}, RHS: []string{"क्त"}},
+		{LHS: []string{"R"}, RHS: []string{"ऱ"}},
+	}, consonants.Mappings.All(), "an override takes the place of the mapping it replaces")
+	assert.Empty(t, others.Mappings.All(), "an override takes its LHS from other categories")
+	assert.NoError(t, flat.Validate())
+
+	// The overlay and the base are left as they were
+	consonants, overlayConsonants := base.Categories["consonants"], overlay.Categories["consonants"]
+	assert.Equal(t, []string{"ch", "c"}, consonants.Mappings.All()[1].LHS)
+	assert.Len(t, overlayConsonants.Mappings.All(), 3)
+}
+
+func TestFlattenErrors(t *testing.T) {
+	base := TransliterationScheme{
+		ID: "base",
+		Categories: map[string]Section{
+			"consonants": {Mappings: core.NewMappings([]core.Mapping{{LHS: []string{"k"}, RHS: []string{"क"}}})},
+		},
+	}
+
+	overlay := TransliterationScheme{ID: "overlay", Extends: "base", Remove: &Removal{LHS: []string{"q"}}}
+	_, err := overlay.Flatten(base)
+	assert.EqualError(t, err, `keymap 'overlay' removes LHS "q", which 'base' does not map`)
+
+	overlay.Remove = &Removal{Categories: []string{"vedic"}}
+	_, err = overlay.Flatten(base)
+	assert.EqualError(t, err, "keymap 'overlay' removes category 'vedic', which 'base' does not have")
+
+	assert.Error(t, overlay.Validate(), "an overlay is validated once flattened")
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Role is the part a category plays in the writing system. The engine decides
+// how to treat a mapping by the role of its category, never by the category's
+// name, so keymap authors can name and split categories freely.
+type Role string
+
+// The roles a category can play.
+const (
+	RoleConsonant        Role = "consonant"         // Consonants, which carry an inherent vowel
+	RoleDependentVowel   Role = "dependent_vowel"   // Vowel signs and the virama, written after a consonant
+	RoleIndependentVowel Role = "independent_vowel" // Vowels, written as a sign after a consonant in forward keymaps
+	RoleOther            Role = "other"             // Marks, digits, punctuation and control outputs, written as they are
+)
+
+// roles lists every valid role.
+var roles = []Role{RoleConsonant, RoleDependentVowel, RoleIndependentVowel, RoleOther}
+
+// defaultRoles are the roles of categories with the conventional names, which
+// need not be declared in the "roles" metadata.
+var defaultRoles = map[string]Role{
+	"consonants": RoleConsonant,
+	"matras":     RoleDependentVowel,
+	"vowels":     RoleIndependentVowel,
+	"others":     RoleOther,
+	"digits":     RoleOther,
+}
+
+// RoleOf returns the role of a category: the one declared in the "roles"
+// metadata, else the default for its name, else "".
+func (s *TransliterationScheme) RoleOf(category string) Role {
+	if role, ok := s.Metadata.Roles[category]; ok {
+		return role
+	}
+	return defaultRoles[category]
+}
+
+// validateRoles checks that every declared role is known and names a category
+// of the scheme, and that every category has a role.
+func (s *TransliterationScheme) validateRoles() error {
+	categories := make([]string, 0, len(s.Metadata.Roles))
+	for category := range s.Metadata.Roles {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if _, exists := s.Categories[category]; !exists {
+			return fmt.Errorf("keymap '%s' declares a role for unknown category '%s'", s.ID, category)
+		}
+		if !validRole(s.Metadata.Roles[category]) {
+			return fmt.Errorf("category '%s' in keymap '%s' has an unknown role '%s'", category, s.ID, s.Metadata.Roles[category])
+		}
+	}
+
+	for _, category := range s.CategoryNames() {
+		if s.RoleOf(category) == "" {
+			return fmt.Errorf("category '%s' in keymap '%s' has no role; declare one in the \"roles\" metadata", category, s.ID)
+		}
+	}
+	return nil
+}
+
+// validRole reports whether role is one of the known roles.
+func validRole(role Role) bool {
+	for _, known := range roles {
+		if role == known {
+			return true
+		}
+	}
+	return false
+}
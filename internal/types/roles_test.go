@@ -0,0 +1,57 @@
+package types
+
+import (
+	"testing"
+
+	"aks.go/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleOf(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID:       "roles",
+		Metadata: Metadata{Roles: map[string]Role{"vyanjan": RoleConsonant, "digits": RoleConsonant}},
+	}
+
+	assert.Equal(t, RoleConsonant, scheme.RoleOf("vyanjan"))
+	assert.Equal(t, RoleConsonant, scheme.RoleOf("digits"), "a declared role overrides the default")
+	assert.Equal(t, RoleDependentVowel, scheme.RoleOf("matras"))
+	assert.Equal(t, Role(""), scheme.RoleOf("vedic"))
+
+	var compiled *CompiledScheme
+	assert.Equal(t, RoleConsonant, compiled.Role("consonants"), "a nil scheme knows the default roles")
+}
+
+func TestValidateRoles(t *testing.T) {
+	section := Section{Mappings: core.NewMappings([]core.Mapping{{LHS: []string{"k"}, RHS: []string{"क"}}})}
+
+	tests := []struct {
+		name     string
+		roles    map[string]Role
+		expected string
+	}{
+		{"Declared", map[string]Role{"vedic": RoleOther}, ""},
+		{"Missing", nil, "category 'vedic' in keymap 'roles' has no role; declare one in the \"roles\" metadata"},
+		{"Unknown role", map[string]Role{"vedic": "accent"}, "category 'vedic' in keymap 'roles' has an unknown role 'accent'"},
+		{"Unknown category", map[string]Role{"vedic": RoleOther, "svar": RoleIndependentVowel}, "keymap 'roles' declares a role for unknown category 'svar'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := TransliterationScheme{
+				ID:         "roles",
+				Name:       "Roles",
+				Language:   "Devanagari",
+				Scheme:     SchemeITRANS,
+				Metadata:   Metadata{Roles: tt.roles},
+				Categories: map[string]Section{"consonants": section, "vedic": section},
+			}
+			err := scheme.Validate()
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContextualRule represents a rule for modifying output based on context.
+// A rule is written as a parenthesized group of space-separated terms after
+// the base output of an RHS, followed by the text it writes; the grammar is
+// documented in docs/contextual_rules.md.
+type ContextualRule struct {
+	ChangePrevious     bool        // (c) flag
+	Replace            int         // (cN) or (uN) - number of graphemes or units ChangePrevious replaces; 0 means 1
+	Units              bool        // (u) flag - ChangePrevious replaces whole output units instead of graphemes
+	RequiredContext    string      // (M) - required context for rule to apply
+	NewContext         string      // (m) - context to set after applying rule
+	WhitespaceRequired bool        // (W) flag - requires next char to be whitespace or EOS
+	Conditions         []Condition // Further conditions, all of which must hold
+	Modification       string      // The actual modification to apply
+}
+
+// ConditionKind identifies what a rule condition tests.
+type ConditionKind int
+
+// The conditions a rule can test, and the terms that write them.
+const (
+	WordInitial      ConditionKind = iota // initial - the token starts a word
+	WordFinal                             // final - the token ends a word, like (W)
+	PreviousCategory                      // prev:CATEGORY - the previous token belongs to CATEGORY
+	NextKey                               // next:KEY - the input after the token starts with KEY
+	NextCategory                          // nextcat:CATEGORY - the next input key belongs to CATEGORY
+	InContext                             // M... - the current context marker, like (M)
+)
+
+// Condition is a single test of a contextual rule, optionally negated with "!".
+type Condition struct {
+	Kind    ConditionKind
+	Value   string // The category, key or context marker tested
+	Negated bool
+}
+
+// String returns the condition as written in a rule.
+func (c Condition) String() string {
+	var term string
+	switch c.Kind {
+	case WordInitial:
+		term = "initial"
+	case WordFinal:
+		term = "final"
+	case PreviousCategory:
+		term = "prev:" + c.Value
+	case NextKey:
+		term = "next:" + c.Value
+	case NextCategory:
+		term = "nextcat:" + c.Value
+	case InContext:
+		term = c.Value
+	}
+	if c.Negated {
+		return "!" + term
+	}
+	return term
+}
+
+// valuePrefixes maps the prefixes of conditions that take a value to their kinds.
+var valuePrefixes = []struct {
+	prefix string
+	kind   ConditionKind
+}{
+	{"prev:", PreviousCategory},
+	{"nextcat:", NextCategory},
+	{"next:", NextKey},
+}
+
+// parseRule parses the terms of a single parenthesized rule.
+func parseRule(ruleStr string) (ContextualRule, error) {
+	var rule ContextualRule
+	terms := strings.Fields(ruleStr)
+	if len(terms) == 0 {
+		return rule, fmt.Errorf("empty rule")
+	}
+
+	for _, term := range terms {
+		negated := strings.HasPrefix(term, "!")
+		name := strings.TrimPrefix(term, "!")
+		if name == "" {
+			return rule, fmt.Errorf("'!' must precede a condition")
+		}
+
+		if condition, ok, err := parseCondition(name); err != nil {
+			return rule, err
+		} else if ok {
+			condition.Negated = negated
+			switch {
+			case condition.Kind == WordFinal && name == "W" && !negated:
+				rule.WhitespaceRequired = true
+			case condition.Kind == InContext && !negated:
+				rule.RequiredContext = name
+			default:
+				rule.Conditions = append(rule.Conditions, condition)
+			}
+			continue
+		}
+
+		// Everything else is an action, which cannot be negated
+		if negated {
+			return rule, fmt.Errorf("action '%s' cannot be negated", name)
+		}
+		switch {
+		case name[0] == 'c' || name[0] == 'u':
+			count := 1
+			if len(name) > 1 {
+				n, err := strconv.Atoi(name[1:])
+				if err != nil || n < 1 {
+					return rule, fmt.Errorf("invalid term '%s': (%c) takes a positive count", name, name[0])
+				}
+				count = n
+			}
+			rule.ChangePrevious = true
+			rule.Replace = count
+			rule.Units = name[0] == 'u'
+		case name[0] == 'x':
+			rule.NewContext = name
+		default:
+			return rule, fmt.Errorf("unknown term '%s'", name)
+		}
+	}
+	return rule, nil
+}
+
+// parseCondition parses a condition term. It reports false for terms that are
+// not conditions and an error for conditions missing their value.
+func parseCondition(name string) (Condition, bool, error) {
+	switch {
+	case name == "W" || name == "final":
+		return Condition{Kind: WordFinal}, true, nil
+	case name == "initial":
+		return Condition{Kind: WordInitial}, true, nil
+	case name[0] == 'M':
+		return Condition{Kind: InContext, Value: name}, true, nil
+	}
+	for _, p := range valuePrefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			value := name[len(p.prefix):]
+			if value == "" {
+				return Condition{}, false, fmt.Errorf("condition '%s' needs a value", name)
+			}
+			return Condition{Kind: p.kind, Value: value}, true, nil
+		}
+	}
+	return Condition{}, false, nil
+}
+
+// ParseRules parses the RHS string into its base output and contextual rules.
+// Unlike ParseContextualRules it rejects malformed rules, reporting the first
+// error with its byte offset in rhs. Legacy markers, such as "(t)", are not
+// errors: they are skipped with the text after them, as the engine always
+// did, and LegacyRules describes them.
+func ParseRules(rhs string) (string, []ContextualRule, error) {
+	base, rules, _, err := parseRules(rhs, true)
+	return base, rules, err
+}
+
+// ParseContextualRules parses the RHS string to extract contextual rules.
+// Returns the base output and any contextual rules found; malformed rules,
+// such as legacy markers the engine does not know, are skipped.
+func ParseContextualRules(rhs string) (string, []ContextualRule) {
+	base, rules, _, _ := parseRules(rhs, false)
+	return base, rules
+}
+
+// LegacyRules describes the parts of rhs written in the legacy marker syntax
+// of converted .akt keymaps, which the rule grammar reads differently or not
+// at all: markers it does not know, such as "(t)", and "(c)" or "(u)" without
+// text directly followed by another group, as in "(c)(M)ం", which legacy
+// keymaps wrote for a single rule, "(M c)ం", but which deletes the previous
+// output. Lint and Warnings report them so they can be rewritten.
+func LegacyRules(rhs string) []string {
+	_, _, legacy, _ := parseRules(rhs, false)
+	return legacy
+}
+
+// isLegacyMarker reports whether the content of a rule group is a single
+// marker of letters that the grammar does not know, such as the "t" of "(t)".
+func isLegacyMarker(content string) bool {
+	if content == "" || strings.ContainsAny(content, " !:") {
+		return false
+	}
+	for _, r := range content {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	_, err := parseRule(content)
+	return err != nil
+}
+
+// parseRules splits rhs at its rule groups and describes the legacy markers
+// among them, which are skipped with the text after them. In strict mode the
+// first malformed group is returned as an error; otherwise it is skipped too.
+func parseRules(rhs string, strict bool) (string, []ContextualRule, []string, error) {
+	// Find the first rule marker
+	firstRule := strings.Index(rhs, "(")
+	if firstRule == -1 {
+		if i := strings.Index(rhs, ")"); i != -1 && strict {
+			return rhs, nil, nil, fmt.Errorf("unmatched ')' at offset %d", i)
+		}
+		return rhs, nil, nil, nil // No rules found
+	}
+
+	// Extract base output (text before first rule)
+	baseOutput := rhs[:firstRule]
+	if i := strings.Index(baseOutput, ")"); i != -1 && strict {
+		return baseOutput, nil, nil, fmt.Errorf("unmatched ')' at offset %d", i)
+	}
+
+	var rules []ContextualRule
+	var legacy []string
+	for start := firstRule; start < len(rhs); {
+		end := strings.Index(rhs[start:], ")")
+		if end == -1 {
+			if strict {
+				return baseOutput, nil, nil, fmt.Errorf("unclosed '(' at offset %d", start)
+			}
+			break
+		}
+		end += start
+		content := rhs[start+1 : end]
+
+		// The text up to the next group is what the rule writes
+		next := strings.Index(rhs[end+1:], "(")
+		if next == -1 {
+			next = len(rhs)
+		} else {
+			next += end + 1
+		}
+		modification := rhs[end+1 : next]
+
+		rule, err := parseRule(content)
+		if err == nil && strings.Contains(content, "(") {
+			err = fmt.Errorf("nested '('")
+		}
+		if err == nil && strings.Contains(modification, ")") {
+			err = fmt.Errorf("unmatched ')' at offset %d", end+1+strings.Index(modification, ")"))
+		}
+		switch {
+		case err != nil && isLegacyMarker(content) && !strings.Contains(modification, ")"):
+			legacy = append(legacy, fmt.Sprintf("legacy marker '(%s)' at offset %d is ignored with the text after it", content, start))
+		case err != nil:
+			if strict {
+				return baseOutput, nil, nil, fmt.Errorf("rule at offset %d: %v", start, err)
+			}
+		default:
+			if rule.ChangePrevious && modification == "" && next < len(rhs) && next == end+1 {
+				legacy = append(legacy, fmt.Sprintf("'(%s)' at offset %d replaces the previous output with nothing; legacy keymaps wrote the conditions of a rule in the groups after it", content, start))
+			}
+			rule.Modification = modification
+			rules = append(rules, rule)
+		}
+		start = next
+	}
+
+	return baseOutput, rules, legacy, nil
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"testing"
+
+	"aks.go/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name          string
+		rhs           string
+		expectedBase  string
+		expectedRules []ContextualRule
+	}{
+		{
+			name:         "Each group is a rule of its own",
+			rhs:          "क(c)(M)ं(x)",
+			expectedBase: "क",
+			expectedRules: []ContextualRule{
+				{ChangePrevious: true, Replace: 1},
+				{RequiredContext: "M", Modification: "ं"},
+				{NewContext: "x"},
+			},
+		},
+		{
+			name:         "Conditions and actions in one group",
+			rhs:          "(M c x)ం",
+			expectedBase: "",
+			expectedRules: []ContextualRule{
+				{ChangePrevious: true, Replace: 1, RequiredContext: "M", NewContext: "x", Modification: "ం"},
+			},
+		},
+		{
+			name:         "Several conditions in one rule",
+			rhs:          "।(prev:digits nextcat:digits c).",
+			expectedBase: "।",
+			expectedRules: []ContextualRule{
+				{
+					ChangePrevious: true,
+					Replace:        1,
+					Conditions: []Condition{
+						{Kind: PreviousCategory, Value: "digits"},
+						{Kind: NextCategory, Value: "digits"},
+					},
+					Modification: ".",
+				},
+			},
+		},
+		{
+			name:         "Negated and word position conditions",
+			rhs:          "ए(!initial !W !Mx next:k c2)ऎ",
+			expectedBase: "ए",
+			expectedRules: []ContextualRule{
+				{
+					ChangePrevious: true,
+					Replace:        2,
+					Conditions: []Condition{
+						{Kind: WordInitial, Negated: true},
+						{Kind: WordFinal, Negated: true},
+						{Kind: InContext, Value: "Mx", Negated: true},
+						{Kind: NextKey, Value: "k"},
+					},
+					Modification: "ऎ",
+				},
+			},
+		},
+		{
+			name:          "No rules",
+			rhs:           "क्ष",
+			expectedBase:  "क्ष",
+			expectedRules: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, rules, err := ParseRules(tt.rhs)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedBase, base)
+			assert.Equal(t, tt.expectedRules, rules)
+		})
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		rhs      string
+		expected string
+	}{
+		{"क()", "rule at offset 3: empty rule"},
+		{"क(t1)ङ", "rule at offset 3: unknown term 't1'"},
+		{"क(t next:k)ङ", "rule at offset 3: unknown term 't'"},
+		{"क(W", "unclosed '(' at offset 3"},
+		{"क)", "unmatched ')' at offset 3"},
+		{"क(W)ं)", "rule at offset 3: unmatched ')' at offset 9"},
+		{"क(!c)", "rule at offset 3: action 'c' cannot be negated"},
+		{"क(c0)", "rule at offset 3: invalid term 'c0': (c) takes a positive count"},
+		{"क(prev:)", "rule at offset 3: condition 'prev:' needs a value"},
+		{"क(! W)", "rule at offset 3: '!' must precede a condition"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rhs, func(t *testing.T) {
+			_, _, err := ParseRules(tt.rhs)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, err.Error())
+			}
+		})
+	}
+
+	// The lenient parser skips what it cannot parse
+	base, rules := ParseContextualRules("न(t)ङ(W)ं")
+	assert.Equal(t, "न", base)
+	assert.Equal(t, []ContextualRule{{WhitespaceRequired: true, Modification: "ं"}}, rules)
+}
+
+func TestLegacyRules(t *testing.T) {
+	// Legacy markers are skipped with their text, as by the lenient parser
+	base, rules, err := ParseRules("न(t)ङ(W)ं")
+	assert.NoError(t, err)
+	assert.Equal(t, "न", base)
+	assert.Equal(t, []ContextualRule{{WhitespaceRequired: true, Modification: "ं"}}, rules)
+	assert.Equal(t, []string{"legacy marker '(t)' at offset 3 is ignored with the text after it"}, LegacyRules("न(t)ङ(W)ं"))
+	section := Section{Mappings: core.NewMappings([]core.Mapping{{LHS: []string{"n"}, RHS: []string{"न", "(t)ङ"}}})}
+	assert.NoError(t, validateRules("consonants", "legacy", section), "keymaps with legacy markers still load")
+
+	// Legacy keymaps wrote one rule in several groups, which now delete
+	assert.Equal(t, []string{
+		"'(c)' at offset 0 replaces the previous output with nothing; legacy keymaps wrote the conditions of a rule in the groups after it",
+	}, LegacyRules("(c)(M)ం(x)"))
+	assert.Empty(t, LegacyRules("(M c x)ం"))
+	assert.Empty(t, LegacyRules("क(W c)"), "a deletion that ends the RHS is meant")
+}
+
+func TestConditionString(t *testing.T) {
+	_, rules, err := ParseRules("(!initial final prev:vowels next:k nextcat:digits !Mx)")
+	assert.NoError(t, err)
+
+	var terms []string
+	for _, condition := range rules[0].Conditions {
+		terms = append(terms, condition.String())
+	}
+	assert.Equal(t, []string{"!initial", "final", "prev:vowels", "next:k", "nextcat:digits", "!Mx"}, terms)
+}
@@ -16,26 +16,49 @@
 package types
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"aks.go/internal/core"
+	"aks.go/internal/norm"
+)
+
+// Input schemes understood by the engine. SchemeUnicode keymaps map native script
+// back to a romanization; all other schemes map a romanization to native script.
+const (
+	SchemeUnicode  = "Unicode"
+	SchemeITRANS   = "ITRANS"
+	SchemeRTS      = "RTS"
+	SchemeIAST     = "IAST"
+	SchemeISO15919 = "ISO15919"
 )
 
 // TransliterationScheme represents a keymap for transliteration.
 // It contains various fields that define the transliteration scheme,
 // including comments, version, ID, name, license, language, and categories.
 type TransliterationScheme struct {
-	Comments   []string           `json:"comments,omitempty"`
-	Version    string             `json:"version"`
-	ID         string             `json:"id"`
-	Name       string             `json:"name"`
-	License    string             `json:"license"`
-	Language   string             `json:"language"`
-	Scheme     string             `json:"scheme"`
+	Comments []string `json:"comments,omitempty"`
+	Version  string   `json:"version"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	License  string   `json:"license"`
+	Language string   `json:"language"`
+	Scheme   string   `json:"scheme"`
+	Extends  string   `json:"extends,omitempty"` // ID of the base keymap of an overlay; see Flatten
+	Remove   *Removal `json:"remove,omitempty"`  // What an overlay drops from its base
+	// Base is the versioned ID of the keymap a flattened overlay was built
+	// from; it is set by Flatten and not read from keymap files
+	Base       string             `json:"base,omitempty"`
 	Metadata   Metadata           `json:"metadata"`
 	Categories map[string]Section `json:"categories"`
+	// Whole-word overrides, matched before the mappings; see Dictionary
+	Words []core.Mapping `json:"words,omitempty"`
+	// Order lists the categories in the order of the keymap file, which is
+	// their precedence; see CategoryNames
+	Order []string `json:"-"`
 }
 
 // Metadata contains additional configuration for a transliteration scheme.
@@ -46,8 +69,25 @@ type Metadata struct {
 	FontSize     int        `json:"font_size,omitempty"`
 	IconEnabled  string     `json:"icon_enabled,omitempty"`
 	IconDisabled string     `json:"icon_disabled,omitempty"`
+
+	// Schwa deletion for reversliteration, SchwaStrict or SchwaDelete
+	Schwa string `json:"schwa,omitempty"`
+	// Native words whose morphemes are analyzed separately, with "+" between them
+	SchwaExceptions []string `json:"schwa_exceptions,omitempty"`
+	// Roles of the categories, by category name; see RoleOf
+	Roles map[string]Role `json:"roles,omitempty"`
+	// Normalization form of the output, "nfc" or "nfd"; empty writes mappings as they are
+	Normalization string `json:"normalization,omitempty"`
+	// Romanization a Unicode keymap writes, such as "ITRANS" or "IAST"
+	Romanization string `json:"romanization,omitempty"`
 }
 
+// Schwa deletion modes for the "schwa" metadata. An empty value means SchwaStrict.
+const (
+	SchwaStrict = "strict" // Write every inherent vowel the virama mode calls for; lossless
+	SchwaDelete = "delete" // Drop the inherent vowels that are silent in speech
+)
+
 // CompactTransliterationScheme is a temporary struct to hold the compact JSON representation
 // of a transliteration scheme. It is used for efficient storage and transmission.
 type CompactTransliterationScheme struct {
@@ -58,8 +98,12 @@ type CompactTransliterationScheme struct {
 	License    string                     `json:"license"`
 	Language   string                     `json:"language"`
 	Scheme     string                     `json:"scheme"`
+	Extends    string                     `json:"extends,omitempty"`
+	Remove     *Removal                   `json:"remove,omitempty"`
 	Metadata   Metadata                   `json:"metadata"`
 	Categories map[string]json.RawMessage `json:"categories"`
+	Words      []core.Mapping             `json:"words,omitempty"`
+	Order      []string                   `json:"-"` // Category order, as in TransliterationScheme
 }
 
 // UnmarshalJSON customizes JSON unmarshaling for TransliterationScheme.
@@ -78,7 +122,23 @@ func (s *TransliterationScheme) UnmarshalJSON(data []byte) error {
 	s.License = compact.License
 	s.Language = compact.Language
 	s.Scheme = compact.Scheme
+	s.Extends = compact.Extends
+	s.Remove = compact.Remove
 	s.Metadata = compact.Metadata
+	s.Words = compact.Words
+
+	// Remember the order of the categories, which sets their precedence
+	var raw struct {
+		Categories json.RawMessage `json:"categories"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	order, err := objectKeys(raw.Categories)
+	if err != nil {
+		return err
+	}
+	s.Order = order
 
 	// Initialize the Categories map
 	s.Categories = make(map[string]Section)
@@ -97,9 +157,36 @@ func (s *TransliterationScheme) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// objectKeys returns the keys of a JSON object in the order they are written.
+func objectKeys(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil { // The opening brace
+		return nil, err
+	}
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
 // Validate checks the integrity of the transliteration scheme.
 // It returns an error if the scheme is invalid.
 func (s *TransliterationScheme) Validate() error {
+	if s.IsOverlay() {
+		return fmt.Errorf("keymap '%s' extends '%s' and must be flattened before it is validated", s.ID, s.Extends)
+	}
 	missingFields := []string{}
 
 	// Validate mandatory fields
@@ -120,14 +207,43 @@ func (s *TransliterationScheme) Validate() error {
 		s.Scheme = "unknown_scheme"
 	}
 
+	if strings.Contains(s.ID, "@") || strings.Contains(s.Version, "@") {
+		return fmt.Errorf("keymap '%s' has '@' in its id or version, where it separates the two", s.ID)
+	}
+
+	switch s.Metadata.Schwa {
+	case "", SchwaStrict, SchwaDelete:
+	default:
+		return fmt.Errorf("keymap '%s' has an invalid schwa mode '%s'", s.ID, s.Metadata.Schwa)
+	}
+	switch s.Metadata.Normalization {
+	case "", norm.FormNFC, norm.FormNFD:
+	default:
+		return fmt.Errorf("keymap '%s' has an invalid normalization form '%s'", s.ID, s.Metadata.Normalization)
+	}
+
 	// Check categories and mappings
 	if len(s.Categories) == 0 {
 		return fmt.Errorf("keymap '%s' has no categories", s.ID)
 	}
+	if err := s.validateRoles(); err != nil {
+		return err
+	}
+	if err := s.validateDuplicates(); err != nil {
+		return err
+	}
 	for category, section := range s.Categories {
 		if err := section.Mappings.ValidateAll(category, s.ID); err != nil {
 			return err
 		}
+		if err := validateRules(category, s.ID, section); err != nil {
+			return err
+		}
+	}
+
+	words := Dictionary{Keymap: s.ID, Words: s.Words}
+	if err := words.Validate(); err != nil {
+		return err
 	}
 
 	if len(missingFields) > 0 {
@@ -137,6 +253,42 @@ func (s *TransliterationScheme) Validate() error {
 	return nil
 }
 
+// Warnings returns the problems of a valid scheme that do not stop it from
+// working: mappings written in a form other than the scheme's normalization
+// form, LHS strings that differ only in normalization, which match the same
+// input, rules in the legacy marker syntax, and the conflicts between
+// categories that precedence resolves.
+func (s *TransliterationScheme) Warnings() []string {
+	var warnings []string
+	form := s.Metadata.Normalization
+	seen := make(map[string]string) // Earlier LHS strings by their NFD form
+	for _, category := range s.CategoryNames() {
+		section := s.Categories[category]
+		for _, mapping := range section.Mappings.All() {
+			for _, lhs := range mapping.LHS {
+				if form != "" && norm.Normalize(form, lhs) != lhs {
+					warnings = append(warnings, fmt.Sprintf("category '%s' in keymap '%s': LHS %q is not in %s", category, s.ID, lhs, strings.ToUpper(form)))
+				}
+				key := norm.NFD(lhs)
+				if earlier, exists := seen[key]; exists && earlier != lhs {
+					warnings = append(warnings, fmt.Sprintf("category '%s' in keymap '%s': LHS %q is equivalent to %q and matches the same input", category, s.ID, lhs, earlier))
+				} else if !exists {
+					seen[key] = lhs
+				}
+			}
+			for _, rhs := range mapping.RHS {
+				if form != "" && norm.Normalize(form, rhs) != rhs {
+					warnings = append(warnings, fmt.Sprintf("category '%s' in keymap '%s': RHS %q is not in %s and is written as %q", category, s.ID, rhs, strings.ToUpper(form), norm.Normalize(form, rhs)))
+				}
+				for _, legacy := range LegacyRules(rhs) {
+					warnings = append(warnings, fmt.Sprintf("category '%s' in keymap '%s': RHS %q: %s", category, s.ID, rhs, legacy))
+				}
+			}
+		}
+	}
+	return append(warnings, s.conflicts()...)
+}
+
 // ToCompactTransliterationScheme converts a TransliterationScheme to a CompactTransliterationScheme.
 // It returns the compact representation of the scheme.
 func ToCompactTransliterationScheme(scheme TransliterationScheme) (CompactTransliterationScheme, error) {
@@ -165,8 +317,12 @@ func ToCompactTransliterationScheme(scheme TransliterationScheme) (CompactTransl
 		License:    scheme.License,
 		Language:   scheme.Language,
 		Scheme:     scheme.Scheme,
+		Extends:    scheme.Extends,
+		Remove:     scheme.Remove,
 		Metadata:   scheme.Metadata,
 		Categories: compactCategories,
+		Words:      scheme.Words,
+		Order:      scheme.CategoryNames(),
 	}, nil
 }
 
@@ -181,8 +337,12 @@ func FromCompactTransliterationScheme(compact CompactTransliterationScheme) (Tra
 		License:    compact.License,
 		Language:   compact.Language,
 		Scheme:     compact.Scheme,
+		Extends:    compact.Extends,
+		Remove:     compact.Remove,
 		Metadata:   compact.Metadata,
 		Categories: make(map[string]Section),
+		Words:      compact.Words,
+		Order:      compact.Order,
 	}
 
 	for category, rawMappings := range compact.Categories {
@@ -198,18 +358,76 @@ func FromCompactTransliterationScheme(compact CompactTransliterationScheme) (Tra
 	return scheme, nil
 }
 
+// validateRules checks that the contextual rules of every RHS in a section parse.
+func validateRules(category, schemeID string, section Section) error {
+	for _, mapping := range section.Mappings.All() {
+		for _, rhs := range mapping.RHS {
+			if _, _, err := ParseRules(rhs); err != nil {
+				return fmt.Errorf("category '%s' in keymap '%s' has an invalid rule in %q: %v", category, schemeID, rhs, err)
+			}
+		}
+	}
+	return nil
+}
+
+// VersionedID returns the ID and version of the scheme as "id@version", the
+// form that names a version of a keymap, or the bare ID if it has no version.
+func (s *TransliterationScheme) VersionedID() string {
+	if s.Version == "" {
+		return s.ID
+	}
+	return s.ID + "@" + s.Version
+}
+
+// CategoryNames returns the category names in precedence order: the order of
+// the keymap file, followed by any categories missing from Order, such as those
+// of a scheme built in code, in sorted order. When two categories map the same
+// LHS, or produce the same RHS, the earlier one wins.
+func (s *TransliterationScheme) CategoryNames() []string {
+	return orderedNames(s.Order, s.Categories)
+}
+
+// CategoryNames returns the category names in the same order as
+// TransliterationScheme.CategoryNames.
+func (c *CompactTransliterationScheme) CategoryNames() []string {
+	return orderedNames(c.Order, c.Categories)
+}
+
+// orderedNames returns the keys of categories listed in order, followed by the
+// remaining keys in sorted order.
+func orderedNames[V any](order []string, categories map[string]V) []string {
+	names := make([]string, 0, len(categories))
+	listed := make(map[string]bool, len(order))
+	for _, name := range order {
+		if _, exists := categories[name]; exists && !listed[name] {
+			names = append(names, name)
+			listed[name] = true
+		}
+	}
+	var rest []string
+	for name := range categories {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
 // IterateCategories performs an action on each category and section in the scheme.
-// It takes a function as an argument to apply to each category.
+// It takes a function as an argument to apply to each category, in precedence order.
 func (s *TransliterationScheme) IterateCategories(action func(string, Section)) {
-	for category, section := range s.Categories {
-		action(category, section)
+	for _, category := range s.CategoryNames() {
+		action(category, s.Categories[category])
 	}
 }
 
 // FindMapping looks for a mapping with any matching LHS entry across all sections.
+// Sections are searched in precedence order.
 // Returns the section name, index within that section, and whether the mapping was found.
 func (s *TransliterationScheme) FindMapping(lhs []string) (string, int, bool) {
-	for section, content := range s.Categories {
+	for _, section := range s.CategoryNames() {
+		content := s.Categories[section]
 		mappings := content.Mappings.All()
 		for i, mapping := range mappings {
 			// Check each LHS entry in the mapping for a match with any input LHS
@@ -226,3 +226,50 @@ func TestFindMapping(t *testing.T) {
 		})
 	}
 }
+
+// TestWarnings verifies the normalization warnings of a valid scheme.
+func TestWarnings(t *testing.T) {
+	scheme := TransliterationScheme{
+		ID:       "test",
+		Name:     "Test",
+		Language: "Devanagari",
+		Scheme:   SchemeUnicode,
+		Metadata: Metadata{Normalization: "nfc"},
+		Categories: map[string]Section{
+			"consonants": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"\u0958", "\u0915\u093C"}, RHS: []string{"q"}},
+					{LHS: []string{"क"}, RHS: []string{"k"}},
+				}),
+			},
+			"others": {
+				Mappings: core.NewMappings([]core.Mapping{
+					{LHS: []string{"\u0902"}, RHS: []string{"m\u0323"}},
+				}),
+			},
+		},
+	}
+
+	if err := scheme.Validate(); err != nil {
+		t.Fatalf("Expected a valid scheme, got %v", err)
+	}
+	expected := []string{
+		"category 'consonants' in keymap 'test': LHS \"\u0958\" is not in NFC",
+		"category 'consonants' in keymap 'test': LHS \"\u0915\u093C\" is equivalent to \"\u0958\" and matches the same input",
+		"category 'others' in keymap 'test': RHS \"m\u0323\" is not in NFC and is written as \"\u1E43\"",
+	}
+	warnings := scheme.Warnings()
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %q", len(expected), warnings)
+	}
+	for i := range expected {
+		if warnings[i] != expected[i] {
+			t.Errorf("Warning %d: expected %q, got %q", i, expected[i], warnings[i])
+		}
+	}
+
+	scheme.Metadata.Normalization = "nfkc"
+	if err := scheme.Validate(); err == nil {
+		t.Errorf("Expected an error for an unknown normalization form")
+	}
+}
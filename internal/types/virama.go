@@ -109,28 +109,58 @@ func NewViramaHandler(mode ViramaMode, virama string, ctx *Context) *ViramaHandl
 // ShouldInsertVirama determines if a virama should be inserted based on the current context
 // and the next character to be processed.
 func (vh *ViramaHandler) ShouldInsertVirama(nextOutput string, nextCategory string) bool {
+	insert, _ := vh.ViramaDecision(nextOutput, nextCategory)
+	return insert
+}
+
+// ViramaDecision makes the same decision as ShouldInsertVirama and also returns
+// a short explanation of it, for tracing.
+func (vh *ViramaHandler) ViramaDecision(nextOutput string, nextCategory string) (bool, string) {
 	// If the last character wasn't a consonant, no virama needed
-	if vh.Context.LatestLookup.Category != "consonants" {
-		return false
+	if !vh.isConsonant(vh.Context.LatestLookup.Category) {
+		return false, "previous output is not a consonant"
 	}
 
 	switch vh.Mode {
 	case SmartMode:
 		// In smart mode, only apply virama between consonants
-		return nextCategory == "consonants"
+		if vh.isConsonant(nextCategory) {
+			return true, "consonant follows a consonant"
+		}
+		return false, "smart mode only joins consonants"
 	case NormalMode:
 		// In normal mode, apply virama after consonants when followed by space or another consonant
-		return nextOutput == " " || nextCategory == "consonants"
+		switch {
+		case nextOutput == " ":
+			return true, "space follows a consonant in normal mode"
+		case vh.isConsonant(nextCategory):
+			return true, "consonant follows a consonant"
+		}
+		return false, "neither a space nor a consonant follows the consonant"
 	case UnknownMode:
-		return vh.Context.LatestLookup.Output == nextOutput
+		if vh.Context.LatestLookup.Output == nextOutput {
+			return true, "repeated output in unknown mode"
+		}
+		return false, "unknown virama mode"
 	}
 
-	return false
+	return false, ""
+}
+
+// HandleJoiner determines if a virama must precede a ZWJ or ZWNJ. Joiners only
+// shape conjuncts, so after a consonant the virama is required in every mode.
+func (vh *ViramaHandler) HandleJoiner() bool {
+	return vh.isConsonant(vh.Context.LatestLookup.Category)
+}
+
+// isConsonant reports whether category plays the consonant role in the keymap.
+func (vh *ViramaHandler) isConsonant(category string) bool {
+	return vh.Context.Compiled.Role(category) == RoleConsonant
 }
 
 // HandleEndOfInput determines if a virama should be inserted at the end of input
 func (vh *ViramaHandler) HandleEndOfInput() bool {
-	return vh.Mode == NormalMode && vh.Context.LatestLookup.Category == "consonants"
+	return vh.Mode == NormalMode && vh.isConsonant(vh.Context.LatestLookup.Category)
 }
 
 // HandleSpace determines if and how a space should be handled in the current context
@@ -138,7 +168,7 @@ func (vh *ViramaHandler) HandleEndOfInput() bool {
 // - shouldAddVirama: whether a virama should be added before the space
 // - shouldAddSpace: whether the space should be added to the output
 func (vh *ViramaHandler) HandleSpace() (shouldAddVirama bool, shouldAddSpace bool) {
-	if vh.Mode == NormalMode && vh.Context.LatestLookup.Category == "consonants" {
+	if vh.Mode == NormalMode && vh.isConsonant(vh.Context.LatestLookup.Category) {
 		return true, true
 	}
 	return false, true
@@ -2,6 +2,8 @@ package types
 
 import (
 	"testing"
+
+	"aks.go/internal/core"
 )
 
 // TestParseVirama tests the ParseVirama method of the Aksharamala struct.
@@ -40,3 +42,43 @@ func TestParseVirama(t *testing.T) {
 		}
 	}
 }
+
+// TestShouldInsertVirama verifies virama decisions for each mode, including
+// punctuation, which takes no virama, and joiners after a consonant.
+func TestShouldInsertVirama(t *testing.T) {
+	ctx := NewContext()
+	consonant := core.LookupResult{Output: "क", Category: "consonants", Found: true}
+
+	tests := []struct {
+		mode         ViramaMode
+		nextOutput   string
+		nextCategory string
+		expected     bool
+	}{
+		{SmartMode, "ख", "consonants", true},
+		{SmartMode, " ", "other", false},
+		{SmartMode, "।", "others", false},
+		{NormalMode, "ख", "consonants", true},
+		{NormalMode, " ", "other", true},
+		{NormalMode, "।", "others", false},
+		{NormalMode, ",", "other", false},
+		{NormalMode, "ं", "others", false},
+		{NormalMode, "ा", "vowels", false},
+	}
+
+	for _, test := range tests {
+		ctx.LatestLookup = consonant
+		handler := NewViramaHandler(test.mode, "्", ctx)
+		if got := handler.ShouldInsertVirama(test.nextOutput, test.nextCategory); got != test.expected {
+			t.Errorf("%s mode before %q: expected %v, got %v", test.mode, test.nextOutput, test.expected, got)
+		}
+		if !handler.HandleJoiner() {
+			t.Errorf("%s mode: expected a virama before a joiner after a consonant", test.mode)
+		}
+	}
+
+	ctx.LatestLookup = core.LookupResult{Output: "अ", Category: "vowels", Found: true}
+	if NewViramaHandler(NormalMode, "्", ctx).HandleJoiner() {
+		t.Errorf("Expected no virama before a joiner after a vowel")
+	}
+}
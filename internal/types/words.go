@@ -0,0 +1,185 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"aks.go/internal/core"
+	"aks.go/internal/norm"
+)
+
+// Dictionary holds whole-word overrides for a keymap, kept in a file of its
+// own. Each word is written as a mapping: its LHS strings are the spellings
+// of the word in the keymap's input, and its first RHS is the output. When
+// Reverse names the keymap of the other direction, the words also apply to
+// it, each alternative of the RHS being read back as the first LHS.
+type Dictionary struct {
+	Comments []string       `json:"comments,omitempty"`
+	Keymap   string         `json:"keymap"`
+	Reverse  string         `json:"reverse,omitempty"`
+	Words    []core.Mapping `json:"words"`
+}
+
+// Validate checks that the dictionary names its keymap and that every word
+// has a spelling and an output that are single words.
+func (d *Dictionary) Validate() error {
+	if d.Keymap == "" {
+		return fmt.Errorf("dictionary has no keymap")
+	}
+	for i, word := range d.Words {
+		if len(word.LHS) == 0 || len(word.RHS) == 0 {
+			return fmt.Errorf("word %d of the dictionary for '%s' needs an LHS and an RHS", i, d.Keymap)
+		}
+		for _, lhs := range word.LHS {
+			if !IsWord(lhs) {
+				return fmt.Errorf("word %d of the dictionary for '%s': %q is not a single word", i, d.Keymap, lhs)
+			}
+		}
+		if d.Reverse != "" {
+			for _, rhs := range word.RHS {
+				if !IsWord(rhs) {
+					return fmt.Errorf("word %d of the dictionary for '%s': %q cannot be read back by '%s' as it is not a single word", i, d.Keymap, rhs, d.Reverse)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Reversed returns the words of the dictionary in the direction of its
+// Reverse keymap.
+func (d *Dictionary) Reversed() []core.Mapping {
+	reversed := make([]core.Mapping, 0, len(d.Words))
+	for _, word := range d.Words {
+		reversed = append(reversed, core.Mapping{LHS: word.RHS, RHS: word.LHS[:1], Comment: word.Comment})
+	}
+	return reversed
+}
+
+// IsSeparatorRune reports whether r ends a word: whitespace, punctuation and
+// anything else that is neither a letter nor a mark. Context.IsSeparator tests
+// the rune after a token with it.
+func IsSeparatorRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || !(unicode.IsLetter(r) || unicode.IsMark(r))
+}
+
+// IsWord reports whether s is a single, non-empty word.
+func IsWord(s string) bool {
+	return s != "" && strings.IndexFunc(s, IsSeparatorRune) < 0
+}
+
+// Words is the immutable whole-word lookup of a compiled keymap. Words are
+// found in either normalization form, and, failing an exact match,
+// capitalized or in all capitals, as at the start of a sentence or in a
+// heading. Other changes of letter case do not match, since in schemes such
+// as ITRANS they spell other letters: "delhI" is not "Delhi".
+type Words struct {
+	exact  map[string]string
+	cased  map[string]string // Capitalized and all-capitals forms of the spellings
+	maxLen int               // Longest word in runes
+}
+
+// NewWords builds the lookup for layers of words, where the words of a later
+// layer override those of an earlier one with the same spelling.
+func NewWords(layers ...[]core.Mapping) *Words {
+	words := &Words{exact: make(map[string]string), cased: make(map[string]string)}
+	for _, layer := range layers {
+		for _, word := range layer {
+			if len(word.RHS) == 0 {
+				continue
+			}
+			for _, lhs := range word.LHS {
+				key := norm.NFD(lhs)
+				words.exact[key] = word.RHS[0]
+				words.maxLen = max(words.maxLen, len([]rune(key)), len([]rune(lhs)))
+				for _, form := range caseForms(key) {
+					words.cased[form] = word.RHS[0]
+					words.maxLen = max(words.maxLen, len([]rune(form)))
+				}
+			}
+		}
+	}
+	return words
+}
+
+// caseForms returns spelling capitalized and in all capitals.
+func caseForms(spelling string) []string {
+	first, size := utf8.DecodeRuneInString(spelling)
+	return []string{string(unicode.ToTitle(first)) + spelling[size:], strings.ToUpper(spelling)}
+}
+
+// Lookup returns the output for word, matched exactly or, failing that, as
+// the capitalized or all-capitals form of a spelling.
+func (w *Words) Lookup(word string) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	key := norm.NFD(word)
+	if output, ok := w.exact[key]; ok {
+		return output, true
+	}
+	output, ok := w.cased[key]
+	return output, ok
+}
+
+// Len returns the number of spellings in the lookup.
+func (w *Words) Len() int {
+	if w == nil {
+		return 0
+	}
+	return len(w.exact)
+}
+
+// MaxLength returns the length in runes of the longest word, or 0 if there are none.
+func (w *Words) MaxLength() int {
+	if w == nil {
+		return 0
+	}
+	return w.maxLen
+}
+
+// WordAt returns the output for the word starting at rune offset start of
+// runes and its length in runes. A word starts after a separator, or at start
+// 0 when previous, the rune before runes, is a separator or 0, and runs up to
+// the next separator, so punctuation around it is mapped as usual.
+func (c *CompiledScheme) WordAt(runes []rune, start int, previous rune) (string, int, bool) {
+	if c.words.Len() == 0 || start >= len(runes) || IsSeparatorRune(runes[start]) {
+		return "", 0, false
+	}
+	if start > 0 {
+		previous = runes[start-1]
+	}
+	if previous != 0 && !IsSeparatorRune(previous) {
+		return "", 0, false
+	}
+
+	end := start
+	for end < len(runes) && !IsSeparatorRune(runes[end]) {
+		end++
+		if end-start > c.words.MaxLength() {
+			return "", 0, false
+		}
+	}
+	output, ok := c.words.Lookup(string(runes[start:end]))
+	if !ok {
+		return "", 0, false
+	}
+	return c.Normalize(output), end - start, true
+}
+
+// WithWords returns a copy of c whose words are those of its keymap overlaid
+// with layers, such as the dictionaries loaded for it. The copy shares
+// everything else with c.
+func (c *CompiledScheme) WithWords(layers ...[]core.Mapping) *CompiledScheme {
+	copied := *c
+	copied.words = NewWords(append([][]core.Mapping{c.Scheme.Words}, layers...)...)
+	return &copied
+}
+
+// MaxWordLength returns the length in runes of the longest whole word the
+// keymap overrides, or 0 if it has none.
+func (c *CompiledScheme) MaxWordLength() int {
+	return c.words.MaxLength()
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"testing"
+
+	"aks.go/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWords(t *testing.T) {
+	words := NewWords(
+		[]core.Mapping{{LHS: []string{"Delhi", "Dilli"}, RHS: []string{"दिल्ली"}}, {LHS: []string{"Google"}, RHS: []string{"गूगल"}}},
+		[]core.Mapping{{LHS: []string{"Google"}, RHS: []string{"गुगल"}}},
+	)
+
+	output, ok := words.Lookup("DILLI")
+	assert.True(t, ok, "words are found in all capitals")
+	assert.Equal(t, "दिल्ली", output)
+	_, ok = words.Lookup("dilli")
+	assert.False(t, ok, "a capital letter is not dropped")
+	_, ok = words.Lookup("delhI")
+	assert.False(t, ok, "other letter cases spell other letters")
+	output, _ = words.Lookup("Google")
+	assert.Equal(t, "गुगल", output, "a later layer overrides an earlier one")
+	_, ok = words.Lookup("Delh")
+	assert.False(t, ok)
+	output, ok = NewWords([]core.Mapping{{LHS: []string{"computer"}, RHS: []string{"कंप्यूटर"}}}).Lookup("Computer")
+	assert.True(t, ok, "words are found capitalized")
+	assert.Equal(t, "कंप्यूटर", output)
+	assert.Equal(t, 3, words.Len())
+	assert.Equal(t, 6, words.MaxLength())
+
+	var none *Words
+	_, ok = none.Lookup("Delhi")
+	assert.False(t, ok)
+	assert.Equal(t, 0, none.MaxLength())
+}
+
+func TestWordAt(t *testing.T) {
+	compiled := CompileScheme(TransliterationScheme{
+		ID:     "words",
+		Scheme: SchemeITRANS,
+		Words:  []core.Mapping{{LHS: []string{"Delhi"}, RHS: []string{"दिल्ली"}}},
+	})
+	runes := []rune("(Delhi) aDelhi Delhi")
+
+	output, n, ok := compiled.WordAt(runes, 1, 0)
+	assert.True(t, ok, "punctuation separates words")
+	assert.Equal(t, "दिल्ली", output)
+	assert.Equal(t, 5, n)
+
+	_, _, ok = compiled.WordAt(runes, 9, 0)
+	assert.False(t, ok, "a word does not start inside another")
+	_, _, ok = compiled.WordAt(runes, 8, 0)
+	assert.False(t, ok, "a longer word is not matched")
+	_, _, ok = compiled.WordAt(runes[15:], 0, 'a')
+	assert.False(t, ok, "the rune before the input decides whether a word starts")
+	_, _, ok = compiled.WordAt(runes[15:], 0, ' ')
+	assert.True(t, ok)
+}
+
+func TestDictionaryValidate(t *testing.T) {
+	dictionary := Dictionary{
+		Keymap:  "hindi",
+		Reverse: "rhindi",
+		Words:   []core.Mapping{{LHS: []string{"Delhi", "Dilli"}, RHS: []string{"दिल्ली"}}},
+	}
+	assert.NoError(t, dictionary.Validate())
+	assert.Equal(t, []core.Mapping{{LHS: []string{"दिल्ली"}, RHS: []string{"Delhi"}}}, dictionary.Reversed())
+
+	dictionary.Words = append(dictionary.Words, core.Mapping{LHS: []string{"e-mail"}, RHS: []string{"ईमेल"}})
+	err := dictionary.Validate()
+	if assert.Error(t, err) {
+		assert.Equal(t, `word 1 of the dictionary for 'hindi': "e-mail" is not a single word`, err.Error())
+	}
+	assert.Error(t, (&Dictionary{}).Validate(), "a dictionary names its keymap")
+}
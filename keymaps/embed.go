@@ -0,0 +1,12 @@
+// Package keymaps embeds the shipped keymaps, so binaries
+// can load them wherever they are started from.
+package keymaps
+
+import "embed"
+
+// FS holds the .aksj keymaps of this directory. The sample dictionaries of
+// dictionaries/ change the output of common words, so they are not embedded
+// and are loaded only on request.
+//
+//go:embed *.aksj
+var FS embed.FS